@@ -0,0 +1,274 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pourer/pika_exporter/discovery"
+	"github.com/pourer/pika_exporter/exporter"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+var labelNameReg = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Config is loaded from a YAML or JSON file, the global settings not in the file keep their defaults.
+type Config struct {
+	Global    Global     `yaml:"global"`
+	Instances []Instance `yaml:"instances"`
+}
+
+type Global struct {
+	Namespace          string        `yaml:"namespace"`
+	ListenAddress      string        `yaml:"listen_address"`
+	TelemetryPath      string        `yaml:"telemetry_path"`
+	ScrapePath         string        `yaml:"scrape_path"`
+	ScrapeAnyTarget    bool          `yaml:"scrape_any_target"`
+	LogLevel           string        `yaml:"log_level"`
+	LogFormat          string        `yaml:"log_format"`
+	KeySpaceStatsClock int           `yaml:"keyspace_stats_clock"`
+	ScanCount          int           `yaml:"scan_count"`
+	Timeout            time.Duration `yaml:"timeout"`
+	PoolMaxIdle        int           `yaml:"pool_max_idle"`
+	PoolIdleTimeout    time.Duration `yaml:"pool_idle_timeout"`
+	ScrapeInterval     time.Duration `yaml:"scrape_interval"`
+	ScrapeConcurrency  int           `yaml:"scrape_concurrency"`
+	ClientListInterval time.Duration `yaml:"client_list_interval"`
+	ClientListTopN     int           `yaml:"client_list_top_n"`
+	BinlogFileSize     int64         `yaml:"binlog_file_size"`
+	MetricsFile        string        `yaml:"metrics_file"`
+	Collectors         []string      `yaml:"collectors"`
+	ConfigParameters   []string      `yaml:"config_parameters"`
+	CheckKeys          []string      `yaml:"check_keys"`
+	CheckKeyPatterns   []string      `yaml:"check_key_patterns"`
+}
+
+// Instance is a pika node, the nil Collectors, ConfigParameters, CheckKeys, CheckKeyPatterns and zero Timeout
+// are inherited from Global.
+type Instance struct {
+	Addr             string            `yaml:"addr"`
+	Password         string            `yaml:"password"`
+	Alias            string            `yaml:"alias"`
+	Labels           map[string]string `yaml:"labels"`
+	Timeout          time.Duration     `yaml:"timeout"`
+	Collectors       []string          `yaml:"collectors"`
+	ConfigParameters []string          `yaml:"config_parameters"`
+	CheckKeys        []string          `yaml:"check_keys"`
+	CheckKeyPatterns []string          `yaml:"check_key_patterns"`
+}
+
+// Error is a validation error at the line of the config file.
+type Error struct {
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Line <= 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+type Errors []*Error
+
+func (es Errors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return "invalid config:\n  " + strings.Join(msgs, "\n  ")
+}
+
+func LoadFile(fileName string, global Global) (*Config, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := Load(data, global)
+	if err != nil {
+		return nil, fmt.Errorf("load config file %s failed. %s", fileName, err.Error())
+	}
+	return cfg, nil
+}
+
+// Load decodes the config in YAML or JSON, which is a subset of YAML, and validates it.
+func Load(data []byte, global Global) (*Config, error) {
+	cfg := &Config{Global: global}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if errs := cfg.validate(&root); len(errs) > 0 {
+		return nil, errs
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) validate(root *yaml.Node) Errors {
+	var errs Errors
+	addErr := func(path []interface{}, format string, args ...interface{}) {
+		errs = append(errs, &Error{Line: nodeLine(root, path...), Msg: fmt.Sprintf(format, args...)})
+	}
+
+	g := cfg.Global
+	if _, err := log.ParseLevel(g.LogLevel); err != nil {
+		addErr([]interface{}{"global", "log_level"}, "invalid log_level %q", g.LogLevel)
+	}
+	if g.KeySpaceStatsClock > 23 {
+		addErr([]interface{}{"global", "keyspace_stats_clock"}, "keyspace_stats_clock must be in the range [0, 23], or < 0 to disable")
+	}
+	if g.Timeout < 0 {
+		addErr([]interface{}{"global", "timeout"}, "timeout must not be negative")
+	}
+	if g.ScrapeInterval < 0 {
+		addErr([]interface{}{"global", "scrape_interval"}, "scrape_interval must not be negative")
+	}
+	validateCollectors(g.Collectors, []interface{}{"global", "collectors"}, addErr)
+	validateKeys(g.CheckKeys, []interface{}{"global", "check_keys"}, addErr)
+	validateKeys(g.CheckKeyPatterns, []interface{}{"global", "check_key_patterns"}, addErr)
+
+	addrs := make(map[string]int)
+	for i, instance := range cfg.Instances {
+		path := []interface{}{"instances", i}
+		if instance.Addr == "" {
+			addErr(path, "addr of instance is required")
+		} else if _, _, err := net.SplitHostPort(instance.Addr); err != nil {
+			addErr(append(path, "addr"), "invalid addr %q, must be host:port", instance.Addr)
+		} else if line, ok := addrs[instance.Addr]; ok {
+			addErr(append(path, "addr"), "duplicate addr %q, first declared at line %d", instance.Addr, line)
+		} else {
+			addrs[instance.Addr] = nodeLine(root, append(path, "addr")...)
+		}
+
+		for name := range instance.Labels {
+			if !labelNameReg.MatchString(name) || strings.HasPrefix(name, "__") {
+				addErr(append(path, "labels", name), "invalid label name %q", name)
+			}
+		}
+		if instance.Timeout < 0 {
+			addErr(append(path, "timeout"), "timeout must not be negative")
+		}
+		validateCollectors(instance.Collectors, append(path, "collectors"), addErr)
+		validateKeys(instance.CheckKeys, append(path, "check_keys"), addErr)
+		validateKeys(instance.CheckKeyPatterns, append(path, "check_key_patterns"), addErr)
+	}
+	return errs
+}
+
+func validateCollectors(collectors []string, path []interface{}, addErr func([]interface{}, string, ...interface{})) {
+	for i, name := range collectors {
+		if err := exporter.ValidateCollector(name); err != nil {
+			addErr(append(path[:len(path):len(path)], i), err.Error())
+		}
+	}
+}
+
+func validateKeys(keys []string, path []interface{}, addErr func([]interface{}, string, ...interface{})) {
+	for i, key := range keys {
+		if err := exporter.ValidateKey(key); err != nil {
+			addErr(append(path[:len(path):len(path)], i), err.Error())
+		}
+	}
+}
+
+// nodeLine returns the line of the deepest node found by path, path is made of the keys of
+// mappings and the indexes of sequences.
+func nodeLine(root *yaml.Node, path ...interface{}) int {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	line := node.Line
+	for _, p := range path {
+		var next *yaml.Node
+		switch key := p.(type) {
+		case string:
+			if node.Kind != yaml.MappingNode {
+				return line
+			}
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					line, next = node.Content[i].Line, node.Content[i+1]
+					break
+				}
+			}
+		case int:
+			if node.Kind != yaml.SequenceNode || key >= len(node.Content) {
+				return line
+			}
+			next = node.Content[key]
+			line = next.Line
+		}
+		if next == nil {
+			return line
+		}
+		node = next
+	}
+	return line
+}
+
+// Discovery returns the instances of the config file, or nil if there is none.
+func (cfg *Config) Discovery() discovery.Discovery {
+	if len(cfg.Instances) == 0 {
+		return nil
+	}
+
+	instances := make([]discovery.Instance, len(cfg.Instances))
+	for i, instance := range cfg.Instances {
+		instances[i] = discovery.Instance{
+			Addr:     instance.Addr,
+			Password: instance.Password,
+			Alias:    instance.Alias,
+			Labels:   instance.Labels,
+		}
+	}
+	return discovery.NewStaticDiscovery(instances...)
+}
+
+func (cfg *Config) ExporterOptions() exporter.Options {
+	g := cfg.Global
+	opt := exporter.Options{
+		Namespace:          g.Namespace,
+		KeyPatterns:        g.CheckKeyPatterns,
+		Keys:               g.CheckKeys,
+		ScanCount:          g.ScanCount,
+		StatsClockHour:     g.KeySpaceStatsClock,
+		Timeout:            g.Timeout,
+		Collectors:         g.Collectors,
+		ConfigParameters:   g.ConfigParameters,
+		PoolMaxIdle:        g.PoolMaxIdle,
+		PoolIdleTimeout:    g.PoolIdleTimeout,
+		ScrapeInterval:     g.ScrapeInterval,
+		ScrapeConcurrency:  g.ScrapeConcurrency,
+		ClientListInterval: g.ClientListInterval,
+		ClientListTopN:     g.ClientListTopN,
+		BinlogFileSize:     g.BinlogFileSize,
+		Instances:          make(map[string]exporter.InstanceOptions),
+	}
+	for _, instance := range cfg.Instances {
+		opt.Instances[instance.Addr] = exporter.InstanceOptions{
+			KeyPatterns:      instance.CheckKeyPatterns,
+			Keys:             instance.CheckKeys,
+			Timeout:          instance.Timeout,
+			Collectors:       instance.Collectors,
+			ConfigParameters: instance.ConfigParameters,
+		}
+	}
+	return opt
+}
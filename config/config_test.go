@@ -0,0 +1,92 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pourer/pika_exporter/exporter"
+	"github.com/stretchr/testify/assert"
+)
+
+var defaultGlobal = Global{
+	Namespace:          "pika",
+	ListenAddress:      ":9121",
+	TelemetryPath:      "/metrics",
+	ScrapePath:         "/scrape",
+	LogLevel:           "info",
+	LogFormat:          "json",
+	KeySpaceStatsClock: -1,
+	ScanCount:          100,
+}
+
+func TestLoadFile(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := LoadFile("../contrib/sample_pika_exporter_config.yml", defaultGlobal)
+	assert.NoError(err)
+	assert.Equal(5*time.Second, cfg.Global.Timeout)
+	assert.Len(cfg.Instances, 3)
+	assert.Equal(map[string]string{"cluster": "cluster-a", "env": "prod"}, cfg.Instances[1].Labels)
+
+	opt := cfg.ExporterOptions()
+	assert.Contains(opt.ConfigParameters, "maxclients")
+	assert.Equal(exporter.InstanceOptions{Timeout: 2 * time.Second, Collectors: []string{"info", "replication_lag"}},
+		opt.Instances["192.168.1.3:9221"])
+	assert.Equal([]string{"db0=user_count"}, opt.Instances["192.168.1.4:9221"].Keys)
+
+	instances := cfg.Discovery().GetInstances()
+	assert.Equal("pika-master", instances[0].Alias)
+	assert.Equal("password", instances[0].Password)
+}
+
+func TestLoad_JSON(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := Load([]byte(`{
+	"global": {"namespace": "pika_json"},
+	"instances": [
+		{"addr": "127.0.0.1:9221", "alias": "a", "labels": {"idc": "bj"}}
+	]
+}`), defaultGlobal)
+	assert.NoError(err)
+	assert.Equal("pika_json", cfg.Global.Namespace)
+	assert.Equal("/metrics", cfg.Global.TelemetryPath)
+	assert.Equal("bj", cfg.Instances[0].Labels["idc"])
+}
+
+func TestLoad_Errors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Load([]byte(`global:
+  namespace: pika
+  unknown: 1
+`), defaultGlobal)
+	assert.EqualError(err, "yaml: unmarshal errors:\n  line 3: field unknown not found in type config.Global")
+
+	_, err = Load([]byte(`global:
+  timeout: 5 apples
+`), defaultGlobal)
+	assert.Contains(err.Error(), "line 2:")
+
+	_, err = Load([]byte(`global:
+  log_level: verbose
+  collectors: [info, unknown]
+instances:
+  - alias: no-addr
+  - addr: 127.0.0.1:9221
+    labels:
+      __name: x
+  - addr: 127.0.0.1:9221
+    check_keys: [a=b=c]
+`), defaultGlobal)
+	errs, ok := err.(Errors)
+	assert.True(ok)
+	if assert.Len(errs, 6) {
+		assert.Equal(`line 2: invalid log_level "verbose"`, errs[0].Error())
+		assert.Equal(3, errs[1].Line)
+		assert.Equal(5, errs[2].Line)
+		assert.Equal(`line 8: invalid label name "__name"`, errs[3].Error())
+		assert.Equal(`line 9: duplicate addr "127.0.0.1:9221", first declared at line 6`, errs[4].Error())
+		assert.Equal(`line 10: invalid key list argument: a=b=c`, errs[5].Error())
+	}
+}
@@ -0,0 +1,98 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	LabelNameCodisProductName = "product_name"
+	LabelNameCodisGroupID     = "group_id"
+)
+
+const (
+	codisOverviewPath   = "/topom"
+	codisRequestTimeout = 5 * time.Second
+)
+
+type codisOverview struct {
+	Model struct {
+		ProductName string `json:"product_name"`
+	} `json:"model"`
+	Stats struct {
+		Group struct {
+			Models []codisGroup `json:"models"`
+		} `json:"group"`
+	} `json:"stats"`
+}
+
+type codisGroup struct {
+	ID      int `json:"id"`
+	Servers []struct {
+		Server string `json:"server"`
+	} `json:"servers"`
+}
+
+type codisDiscovery struct {
+	*refresher
+	url      string
+	password string
+	client   *http.Client
+}
+
+// NewCodisDiscovery polls the overview api of codis dashboard(topom), and exposes the servers of every
+// group as the instances. password is the product auth shared by all of the servers.
+func NewCodisDiscovery(dashboardAddr, password string, refreshInterval time.Duration) (*codisDiscovery, error) {
+	url := strings.TrimRight(dashboardAddr, "/")
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = "http://" + url
+	}
+
+	d := &codisDiscovery{
+		url:      url + codisOverviewPath,
+		password: password,
+		client:   &http.Client{Timeout: codisRequestTimeout},
+	}
+
+	var err error
+	if d.refresher, err = newRefresher("codis", refreshInterval, d.reload); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *codisDiscovery) reload() ([]Instance, error) {
+	resp, err := d.client.Get(d.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("codis dashboard %s response status:%s", d.url, resp.Status)
+	}
+
+	var overview codisOverview
+	if err := json.NewDecoder(resp.Body).Decode(&overview); err != nil {
+		return nil, fmt.Errorf("codis dashboard %s response decode failed. err:%s", d.url, err.Error())
+	}
+
+	var instances []Instance
+	for _, group := range overview.Stats.Group.Models {
+		for _, server := range group.Servers {
+			instances = append(instances, Instance{
+				Addr:     server.Server,
+				Password: d.password,
+				Labels: map[string]string{
+					LabelNameCodisProductName: overview.Model.ProductName,
+					LabelNameCodisGroupID:     strconv.Itoa(group.ID),
+				},
+			})
+		}
+	}
+	return instances, nil
+}
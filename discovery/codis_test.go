@@ -0,0 +1,89 @@
+package discovery
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const codisOverviewOneGroup = `{
+  "version": "3.2.2",
+  "model": {"token": "8a3b3a4e", "product_name": "codis-pika", "admin_addr": "127.0.0.1:18080"},
+  "stats": {
+    "closed": false,
+    "group": {
+      "models": [
+        {"id": 1, "servers": [
+          {"server": "127.0.0.1:9221", "datacenter": "", "action": {}, "replica_group": false},
+          {"server": "127.0.0.1:9222", "datacenter": "", "action": {}, "replica_group": false}
+        ], "promoting": {}, "out_of_sync": false}
+      ],
+      "stats": {}
+    }
+  }
+}`
+
+const codisOverviewTwoGroups = `{
+  "model": {"product_name": "codis-pika"},
+  "stats": {
+    "group": {
+      "models": [
+        {"id": 1, "servers": [{"server": "127.0.0.1:9221"}]},
+        {"id": 2, "servers": [{"server": "127.0.0.1:9231"}]}
+      ]
+    }
+  }
+}`
+
+func TestCodisDiscovery(t *testing.T) {
+	assert := assert.New(t)
+
+	var overview atomic.Value
+	overview.Store(codisOverviewOneGroup)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != codisOverviewPath {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(overview.Load().(string)))
+	}))
+	defer ts.Close()
+
+	d, err := NewCodisDiscovery(ts.URL, "auth", 10*time.Millisecond)
+	assert.NoError(err)
+	defer d.Close()
+
+	assert.Equal([]Instance{
+		{
+			Addr:     "127.0.0.1:9221",
+			Password: "auth",
+			Labels:   map[string]string{LabelNameCodisProductName: "codis-pika", LabelNameCodisGroupID: "1"},
+		},
+		{
+			Addr:     "127.0.0.1:9222",
+			Password: "auth",
+			Labels:   map[string]string{LabelNameCodisProductName: "codis-pika", LabelNameCodisGroupID: "1"},
+		},
+	}, d.GetInstances())
+
+	overview.Store(codisOverviewTwoGroups)
+	assert.Eventually(func() bool {
+		instances := d.GetInstances()
+		return len(instances) == 2 && instances[1].Addr == "127.0.0.1:9231" &&
+			instances[1].Labels[LabelNameCodisGroupID] == "2"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCodisDiscovery_Error(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	_, err := NewCodisDiscovery(ts.URL, "", 0)
+	assert.Error(t, err)
+}
@@ -2,8 +2,10 @@ package discovery
 
 import (
 	"encoding/csv"
+	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -16,12 +18,25 @@ type Instance struct {
 	Addr     string
 	Password string
 	Alias    string
+	Labels   map[string]string
 }
 
 type Discovery interface {
 	GetInstances() []Instance
 }
 
+type staticDiscovery struct {
+	instances []Instance
+}
+
+func NewStaticDiscovery(instances ...Instance) *staticDiscovery {
+	return &staticDiscovery{instances: instances}
+}
+
+func (d *staticDiscovery) GetInstances() []Instance {
+	return d.instances
+}
+
 type cmdArgsDiscovery struct {
 	instances []Instance
 }
@@ -56,10 +71,49 @@ func (d *cmdArgsDiscovery) GetInstances() []Instance {
 }
 
 type fileDiscovery struct {
+	*refresher
+	fileName  string
+	modTime   time.Time
+	size      int64
+	loaded    bool
 	instances []Instance
 }
 
-func NewFileDiscovery(fileName string) (*fileDiscovery, error) {
+func NewFileDiscovery(fileName string, reloadInterval time.Duration) (*fileDiscovery, error) {
+	d := &fileDiscovery{fileName: fileName}
+
+	var err error
+	if d.refresher, err = newRefresher("file", reloadInterval, d.reload); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// reload only reads the file again when its modification time or size has changed. It's called by
+// the refresher only, the first time before the refresh loop is started, so its state is not locked.
+func (d *fileDiscovery) reload() ([]Instance, error) {
+	stat, err := os.Stat(d.fileName)
+	if err != nil {
+		return nil, err
+	}
+	if d.loaded && stat.ModTime().Equal(d.modTime) && stat.Size() == d.size {
+		return d.instances, nil
+	}
+
+	instances, err := loadInstancesFromFile(d.fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.loaded {
+		log.Infof("pika hosts file %s reloaded, instances:%d", d.fileName, len(instances))
+	}
+	d.modTime, d.size = stat.ModTime(), stat.Size()
+	d.loaded, d.instances = true, instances
+	return instances, nil
+}
+
+func loadInstancesFromFile(fileName string) ([]Instance, error) {
 	file, err := os.Open(fileName)
 	if err != nil {
 		return nil, err
@@ -77,15 +131,23 @@ func NewFileDiscovery(fileName string) (*fileDiscovery, error) {
 	for _, record := range records {
 		instance := Instance{}
 		length := len(record)
-		switch length {
-		case 3:
+		switch {
+		case length > 3:
+			labels, err := parseLabels(record[3:])
+			if err != nil {
+				log.Warnln("pika hosts file has invalid labels:", record, "err:", err)
+				continue
+			}
+			instance.Labels = labels
+			fallthrough
+		case length == 3:
 			instance.Addr = record[0]
 			instance.Password = record[1]
 			instance.Alias = record[2]
-		case 2:
+		case length == 2:
 			instance.Addr = record[0]
 			instance.Password = record[1]
-		case 1:
+		case length == 1:
 			instance.Addr = record[0]
 		default:
 			log.Warnln("pika hosts file has invalid data:", record)
@@ -95,9 +157,18 @@ func NewFileDiscovery(fileName string) (*fileDiscovery, error) {
 		instances = append(instances, instance)
 	}
 
-	return &fileDiscovery{instances: instances}, nil
+	return instances, nil
 }
 
-func (d *fileDiscovery) GetInstances() []Instance {
-	return d.instances
+// parseLabels parses the labels in the form of name=value.
+func parseLabels(fields []string) (map[string]string, error) {
+	labels := make(map[string]string, len(fields))
+	for _, field := range fields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid label %q, must be name=value", field)
+		}
+		labels[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return labels, nil
 }
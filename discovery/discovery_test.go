@@ -0,0 +1,42 @@
+package discovery
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileDiscovery_Reload(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "pika_exporter")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "hosts")
+	assert.NoError(ioutil.WriteFile(fileName, []byte("127.0.0.1:9221,pwd,a\n127.0.0.1:9222\n127.0.0.1:9224,,d,cluster=c1,idc=bj\n127.0.0.1:9225,,e,bad\n"), 0644))
+
+	d, err := NewFileDiscovery(fileName, 10*time.Millisecond)
+	assert.NoError(err)
+	defer d.Close()
+	assert.Equal([]Instance{
+		{Addr: "127.0.0.1:9221", Password: "pwd", Alias: "a"},
+		{Addr: "127.0.0.1:9222"},
+		{Addr: "127.0.0.1:9224", Alias: "d", Labels: map[string]string{"cluster": "c1", "idc": "bj"}},
+	}, d.GetInstances())
+
+	assert.NoError(ioutil.WriteFile(fileName, []byte("127.0.0.1:9223,,c\n"), 0644))
+	assert.Eventually(func() bool {
+		instances := d.GetInstances()
+		return len(instances) == 1 && instances[0].Addr == "127.0.0.1:9223"
+	}, time.Second, 10*time.Millisecond)
+
+	// a broken file keeps the last instances
+	assert.NoError(os.Remove(fileName))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal([]Instance{{Addr: "127.0.0.1:9223", Alias: "c"}}, d.GetInstances())
+}
@@ -0,0 +1,73 @@
+package discovery
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type loadFunc func() ([]Instance, error)
+
+// refresher calls load every interval and atomically swaps the instances it returns,
+// so that GetInstances never blocks on a reload in progress.
+type refresher struct {
+	name      string
+	instances atomic.Value
+	load      loadFunc
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func newRefresher(name string, interval time.Duration, load loadFunc) (*refresher, error) {
+	instances, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &refresher{
+		name: name,
+		load: load,
+		done: make(chan struct{}),
+	}
+	r.instances.Store(instances)
+
+	if interval > 0 {
+		r.wg.Add(1)
+		go r.loop(interval)
+	}
+	return r, nil
+}
+
+func (r *refresher) loop(interval time.Duration) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		}
+
+		instances, err := r.load()
+		if err != nil {
+			log.Warnf("%s discovery refresh failed, keep the last instances. err:%s", r.name, err.Error())
+			continue
+		}
+		r.instances.Store(instances)
+	}
+}
+
+func (r *refresher) GetInstances() []Instance {
+	return r.instances.Load().([]Instance)
+}
+
+func (r *refresher) Close() error {
+	close(r.done)
+	r.wg.Wait()
+	return nil
+}
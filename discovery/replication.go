@@ -0,0 +1,125 @@
+package discovery
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	LabelNameMaster = "master"
+)
+
+const (
+	replicationDialTimeout = 5 * time.Second
+)
+
+var errNoSeedAvailable = errors.New("no replication seed available")
+
+var slaveInfoReg = regexp.MustCompile(`slave\d+:ip=(?P<slave_ip>[^,\s]+),port=(?P<slave_port>[\d]+)`)
+
+type replicationDiscovery struct {
+	*refresher
+	seeds    []string
+	password string
+	slaves   map[string][]string
+}
+
+// NewReplicationDiscovery discovers the slaves of the seed masters by INFO REPLICATION, and the cascaded
+// slaves of these slaves recursively. Each slave is labeled with the master it was found under. The slaves
+// of an instance which doesn't answer are the last known ones, until it answers again.
+func NewReplicationDiscovery(seeds, password string, refreshInterval time.Duration) (*replicationDiscovery, error) {
+	d := &replicationDiscovery{
+		seeds:    strings.Split(seeds, defaultSeparator),
+		password: password,
+	}
+
+	var err error
+	if d.refresher, err = newRefresher("replication", refreshInterval, d.reload); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *replicationDiscovery) reload() ([]Instance, error) {
+	var (
+		instances []Instance
+		queue     []Instance
+		visited   = make(map[string]struct{})
+		seeds     = make(map[string]struct{})
+		answered  int
+		allSlaves = make(map[string][]string)
+	)
+	for _, seed := range d.seeds {
+		queue = append(queue, Instance{Addr: strings.TrimSpace(seed), Password: d.password})
+		seeds[strings.TrimSpace(seed)] = struct{}{}
+	}
+
+	for len(queue) > 0 {
+		instance := queue[0]
+		queue = queue[1:]
+		if _, ok := visited[instance.Addr]; ok {
+			continue
+		}
+		visited[instance.Addr] = struct{}{}
+		instances = append(instances, instance)
+
+		slaves, err := d.fetchSlaves(instance.Addr)
+		if err != nil {
+			var ok bool
+			if slaves, ok = d.slaves[instance.Addr]; !ok {
+				log.Warnf("replication discovery fetch slaves failed. addr:%s err:%s", instance.Addr, err.Error())
+				continue
+			}
+			log.Warnf("replication discovery fetch slaves failed, keep the last known slaves. addr:%s slaves:%v err:%s",
+				instance.Addr, slaves, err.Error())
+		} else if _, ok := seeds[instance.Addr]; ok {
+			answered++
+		}
+		allSlaves[instance.Addr] = slaves
+
+		for _, slave := range slaves {
+			queue = append(queue, Instance{
+				Addr:     slave,
+				Password: d.password,
+				Labels:   map[string]string{LabelNameMaster: instance.Addr},
+			})
+		}
+	}
+	if answered == 0 {
+		return nil, errNoSeedAvailable
+	}
+
+	d.slaves = allSlaves
+	return instances, nil
+}
+
+func (d *replicationDiscovery) fetchSlaves(addr string) ([]string, error) {
+	conn, err := redis.Dial("tcp", addr,
+		redis.DialConnectTimeout(replicationDialTimeout),
+		redis.DialWriteTimeout(replicationDialTimeout),
+		redis.DialReadTimeout(replicationDialTimeout),
+		redis.DialPassword(d.password))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	info, err := redis.String(conn.Do("INFO", "REPLICATION"))
+	if err != nil {
+		return nil, err
+	}
+	return parseSlaves(info), nil
+}
+
+func parseSlaves(info string) []string {
+	var slaves []string
+	for _, matches := range slaveInfoReg.FindAllStringSubmatch(info, -1) {
+		slaves = append(slaves, matches[1]+":"+matches[2])
+	}
+	return slaves
+}
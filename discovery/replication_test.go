@@ -0,0 +1,96 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pourer/pika_exporter/internal/respserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func newReplicationServer(t *testing.T, slaves func() []string) *respserver.Server {
+	return respserver.New(t, func(args []string) interface{} {
+		if len(args) != 2 || strings.ToUpper(args[0]) != "INFO" {
+			return errors.New("ERR unknown command")
+		}
+
+		info := "# Replication(MASTER)\r\nrole:master\r\n"
+		for i, slave := range slaves() {
+			host, port, _ := net.SplitHostPort(slave)
+			info += fmt.Sprintf("slave%d:ip=%s,port=%s,conn_fd=%d,lag=(db0:0)\r\n", i, host, port, 100+i)
+		}
+		return info
+	})
+}
+
+func TestReplicationDiscovery(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		mu                 sync.Mutex
+		masterSlaves       []string
+		slaveSlaves        []string
+		master, slave, sub *respserver.Server
+	)
+	get := func(s *[]string) func() []string {
+		return func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return *s
+		}
+	}
+
+	master = newReplicationServer(t, get(&masterSlaves))
+	defer master.Close()
+	slave = newReplicationServer(t, get(&slaveSlaves))
+	defer slave.Close()
+	sub = newReplicationServer(t, func() []string { return []string{master.Addr()} })
+	defer sub.Close()
+
+	// master -> slave -> sub -> master, the cycle must be broken.
+	mu.Lock()
+	masterSlaves = []string{slave.Addr()}
+	slaveSlaves = []string{sub.Addr()}
+	mu.Unlock()
+
+	d, err := NewReplicationDiscovery(master.Addr(), "", 10*time.Millisecond)
+	assert.NoError(err)
+	defer d.Close()
+	assert.Equal([]Instance{
+		{Addr: master.Addr()},
+		{Addr: slave.Addr(), Labels: map[string]string{LabelNameMaster: master.Addr()}},
+		{Addr: sub.Addr(), Labels: map[string]string{LabelNameMaster: slave.Addr()}},
+	}, d.GetInstances())
+
+	mu.Lock()
+	slaveSlaves = nil
+	mu.Unlock()
+	assert.Eventually(func() bool {
+		return len(d.GetInstances()) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	slaveSlaves = []string{sub.Addr()}
+	mu.Unlock()
+	assert.Eventually(func() bool {
+		return len(d.GetInstances()) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	// the slaves of the unreachable slave are the last known ones.
+	slave.Close()
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(d.GetInstances(), 3)
+
+	// no seed is reachable, the last instances are kept.
+	master.Close()
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(d.GetInstances(), 3)
+
+	_, err = NewReplicationDiscovery(master.Addr(), "", 0)
+	assert.Equal(errNoSeedAvailable, err)
+}
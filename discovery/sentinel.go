@@ -0,0 +1,134 @@
+package discovery
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	LabelNameSentinelMaster = "sentinel_master"
+	LabelNameRole           = "role"
+)
+
+const (
+	sentinelDialTimeout = 5 * time.Second
+)
+
+var errNoSentinelAvailable = errors.New("no sentinel available")
+
+// sentinelDownFlags are the flags of the slaves which are not discovered, sentinel keeps the slaves
+// it has seen until they are reset.
+var sentinelDownFlags = []string{"s_down", "o_down", "disconnected"}
+
+type sentinelDiscovery struct {
+	*refresher
+	sentinels []string
+	password  string
+}
+
+// NewSentinelDiscovery asks the sentinels for the masters they monitor and the slaves of each master,
+// the first sentinel answered is used. Each instance is labeled with the master name in sentinel, and its
+// role, so that a failover changes the instances. The slaves down or disconnected are skipped.
+func NewSentinelDiscovery(sentinels, password string, refreshInterval time.Duration) (*sentinelDiscovery, error) {
+	d := &sentinelDiscovery{
+		sentinels: strings.Split(sentinels, defaultSeparator),
+		password:  password,
+	}
+
+	var err error
+	if d.refresher, err = newRefresher("sentinel", refreshInterval, d.reload); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *sentinelDiscovery) reload() ([]Instance, error) {
+	for _, sentinel := range d.sentinels {
+		instances, err := d.fetchInstances(strings.TrimSpace(sentinel))
+		if err != nil {
+			log.Warnf("sentinel discovery fetch instances failed. sentinel:%s err:%s", sentinel, err.Error())
+			continue
+		}
+		return instances, nil
+	}
+	return nil, errNoSentinelAvailable
+}
+
+func (d *sentinelDiscovery) fetchInstances(sentinel string) ([]Instance, error) {
+	conn, err := redis.Dial("tcp", sentinel,
+		redis.DialConnectTimeout(sentinelDialTimeout),
+		redis.DialWriteTimeout(sentinelDialTimeout),
+		redis.DialReadTimeout(sentinelDialTimeout))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	masters, err := sentinelValues(conn.Do("SENTINEL", "MASTERS"))
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []Instance
+	for _, master := range masters {
+		name := master["name"]
+		instances = append(instances, d.newInstance(name, "master", master))
+
+		slaves, err := sentinelValues(conn.Do("SENTINEL", "SLAVES", name))
+		if err != nil {
+			return nil, err
+		}
+		for _, slave := range slaves {
+			if sentinelDown(slave["flags"]) {
+				log.Debugf("sentinel discovery slave skipped. master:%s addr:%s:%s flags:%s",
+					name, slave["ip"], slave["port"], slave["flags"])
+				continue
+			}
+			instances = append(instances, d.newInstance(name, "slave", slave))
+		}
+	}
+	return instances, nil
+}
+
+func (d *sentinelDiscovery) newInstance(masterName, role string, fields map[string]string) Instance {
+	return Instance{
+		Addr:     net.JoinHostPort(fields["ip"], fields["port"]),
+		Password: d.password,
+		Labels:   map[string]string{LabelNameSentinelMaster: masterName, LabelNameRole: role},
+	}
+}
+
+func sentinelDown(flags string) bool {
+	for _, flag := range strings.Split(flags, ",") {
+		for _, down := range sentinelDownFlags {
+			if flag == down {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sentinelValues converts the reply of SENTINEL MASTERS and SENTINEL SLAVES, which is an array of
+// flat field-value arrays.
+func sentinelValues(reply interface{}, err error) ([]map[string]string, error) {
+	values, err := redis.Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+
+	ms := make([]map[string]string, 0, len(values))
+	for _, v := range values {
+		m, err := redis.StringMap(v, nil)
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
@@ -0,0 +1,93 @@
+package discovery
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pourer/pika_exporter/internal/respserver"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSentinel struct {
+	sync.Mutex
+	masters map[string][]string
+	flags   map[string]string
+}
+
+func (s *fakeSentinel) failover(name string) {
+	s.Lock()
+	defer s.Unlock()
+	addrs := s.masters[name]
+	s.masters[name] = append(addrs[1:], addrs[0])
+}
+
+func (s *fakeSentinel) handle(args []string) interface{} {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(args) < 2 || strings.ToUpper(args[0]) != "SENTINEL" {
+		return errors.New("ERR unknown command")
+	}
+	switch strings.ToUpper(args[1]) {
+	case "MASTERS":
+		var masters []interface{}
+		for _, name := range []string{"group1", "group2"} {
+			ip, port := splitAddr(s.masters[name][0])
+			masters = append(masters, []string{"name", name, "ip", ip, "port", port, "flags", "master"})
+		}
+		return masters
+	case "SLAVES":
+		var slaves []interface{}
+		for _, addr := range s.masters[args[2]][1:] {
+			ip, port := splitAddr(addr)
+			flags := "slave"
+			if s.flags[addr] != "" {
+				flags += "," + s.flags[addr]
+			}
+			slaves = append(slaves, []string{"name", addr, "ip", ip, "port", port, "flags", flags})
+		}
+		return slaves
+	}
+	return errors.New("ERR unknown sentinel subcommand")
+}
+
+func splitAddr(addr string) (string, string) {
+	i := strings.LastIndex(addr, ":")
+	return addr[:i], addr[i+1:]
+}
+
+func TestSentinelDiscovery(t *testing.T) {
+	assert := assert.New(t)
+
+	sentinel := &fakeSentinel{masters: map[string][]string{
+		"group1": {"10.0.0.1:9221", "10.0.0.2:9221", "10.0.0.4:9221"},
+		"group2": {"10.0.0.3:9221"},
+	}, flags: map[string]string{"10.0.0.4:9221": "s_down,disconnected"}}
+	s := respserver.New(t, sentinel.handle)
+	defer s.Close()
+
+	// the first sentinel is unavailable, the slave down is skipped.
+	d, err := NewSentinelDiscovery("127.0.0.1:1,"+s.Addr(), "pwd", 10*time.Millisecond)
+	assert.NoError(err)
+	defer d.Close()
+	assert.Equal([]Instance{
+		{Addr: "10.0.0.1:9221", Password: "pwd", Labels: map[string]string{LabelNameSentinelMaster: "group1", LabelNameRole: "master"}},
+		{Addr: "10.0.0.2:9221", Password: "pwd", Labels: map[string]string{LabelNameSentinelMaster: "group1", LabelNameRole: "slave"}},
+		{Addr: "10.0.0.3:9221", Password: "pwd", Labels: map[string]string{LabelNameSentinelMaster: "group2", LabelNameRole: "master"}},
+	}, d.GetInstances())
+
+	sentinel.failover("group1")
+	assert.Eventually(func() bool {
+		instances := d.GetInstances()
+		return instances[0].Addr == "10.0.0.2:9221" && instances[0].Labels[LabelNameRole] == "master" &&
+			instances[1].Addr == "10.0.0.1:9221" && instances[1].Labels[LabelNameRole] == "slave"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSentinelDiscovery_Unavailable(t *testing.T) {
+	_, err := NewSentinelDiscovery("127.0.0.1:1", "", 0)
+	assert.Equal(t, errNoSentinelAvailable, err)
+}
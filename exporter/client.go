@@ -1,15 +1,20 @@
 package exporter
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/garyburd/redigo/redis"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
 	defaultScanCount = 100
+	defaultDB        = "0"
 )
 
 const (
@@ -31,35 +36,93 @@ type keyInfo struct {
 	keyType string
 }
 
+// slowlogEntry is an entry of SLOWLOG GET, only the command name of the arguments is kept.
+type slowlogEntry struct {
+	id       int64
+	command  string
+	duration time.Duration
+}
+
 type client struct {
 	addr, alias string
 	conn        redis.Conn
+	db          string
+	// ctx bounds every command by its deadline, in addition to timeout.
+	ctx     context.Context
+	timeout time.Duration
 }
 
-func newClient(addr, password, alias string) (*client, error) {
-	conn, err := redis.Dial("tcp", addr,
-		redis.DialConnectTimeout(5*time.Second),
-		redis.DialWriteTimeout(5*time.Second),
-		redis.DialReadTimeout(5*time.Second),
-		redis.DialPassword(password))
-	if err != nil {
+func newClient(ctx context.Context, addr, alias string, conn redis.Conn, timeout time.Duration) (*client, error) {
+	if err := conn.Err(); err != nil {
+		conn.Close()
 		return nil, err
 	}
 
 	return &client{
-		addr:  addr,
-		alias: alias,
-		conn:  conn,
+		addr:    addr,
+		alias:   alias,
+		conn:    conn,
+		ctx:     ctx,
+		timeout: timeout,
 	}, nil
 }
 
+func dial(addr, password string, timeout time.Duration) (redis.Conn, error) {
+	return redis.Dial("tcp", addr,
+		redis.DialConnectTimeout(timeout),
+		redis.DialWriteTimeout(timeout),
+		redis.DialReadTimeout(timeout),
+		redis.DialPassword(password))
+}
+
+// Close returns the connection to the pool, the db is selected back to 0 if it has been changed,
+// so that the next borrower of the connection is not affected.
 func (c *client) Close() error {
 	if c.conn == nil {
 		return nil
 	}
+	if c.db != "" && c.db != defaultDB && c.conn.Err() == nil {
+		if _, err := c.conn.Do("SELECT", defaultDB); err != nil {
+			log.Warnf("client::Close select db back to %s failed. addr:%s err:%s", defaultDB, c.addr, err.Error())
+		}
+	}
 	return c.conn.Close()
 }
 
+// do executes the command with the timeout of the remaining time to the deadline of ctx, the command
+// is not sent at all once the deadline has passed.
+func (c *client) do(commandName string, args ...interface{}) (interface{}, error) {
+	if c.expired() {
+		return nil, context.DeadlineExceeded
+	}
+	deadline, ok := c.ctx.Deadline()
+	if !ok {
+		return c.conn.Do(commandName, args...)
+	}
+
+	timeout := time.Until(deadline)
+	if c.timeout > 0 && c.timeout < timeout {
+		timeout = c.timeout
+	}
+	return redis.DoWithTimeout(c.conn, timeout, commandName, args...)
+}
+
+// expired reports whether the deadline of ctx has passed, the errors of the commands are caused by
+// the deadline then, rather than the pika instance.
+func (c *client) expired() bool {
+	return deadlineExceeded(c.ctx)
+}
+
+// deadlineExceeded checks the deadline by time, since ctx.Err() is set by a timer which may fire
+// a little later than the read deadline of the connection.
+func deadlineExceeded(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline)
+}
+
 func (c *client) Addr() string {
 	return c.addr
 }
@@ -69,22 +132,94 @@ func (c *client) Alias() string {
 }
 
 func (c *client) Select(db string) error {
-	_, err := c.conn.Do("SELECT", db)
-	return err
+	if _, err := c.do("SELECT", db); err != nil {
+		return err
+	}
+	c.db = db
+	return nil
 }
 
 func (c *client) Info() (string, error) {
-	return redis.String(c.conn.Do("INFO", "ALL"))
+	return redis.String(c.do("INFO", "ALL"))
 }
 
 func (c *client) InfoKeySpaceZero() (string, error) {
-	return redis.String(c.conn.Do("INFO", "KEYSPACE", 0))
+	return redis.String(c.do("INFO", "KEYSPACE", 0))
 }
 
 func (c *client) InfoKeySpaceOne() (string, error) {
-	return redis.String(c.conn.Do("INFO", "KEYSPACE", 1))
+	return redis.String(c.do("INFO", "KEYSPACE", 1))
+}
+
+func (c *client) ClientList() (string, error) {
+	return redis.String(c.do("CLIENT", "LIST"))
+}
+
+// ConfigGet returns the parameters matching the pattern and their values.
+func (c *client) ConfigGet(pattern string) (map[string]string, error) {
+	return redis.StringMap(c.do("CONFIG", "GET", pattern))
+}
+
+// Uptime returns uptime_in_seconds of INFO SERVER.
+func (c *client) Uptime() (int64, error) {
+	info, err := redis.String(c.do("INFO", "SERVER"))
+	if err != nil {
+		return 0, err
+	}
+	const key = "uptime_in_seconds:"
+	for _, line := range strings.Split(info, "\n") {
+		if strings.HasPrefix(line, key) {
+			return strconv.ParseInt(strings.TrimSpace(line[len(key):]), 10, 64)
+		}
+	}
+	return 0, errors.New("uptime_in_seconds not found in INFO SERVER")
+}
+
+func (c *client) SlowlogLen() (int, error) {
+	return redis.Int(c.do("SLOWLOG", "LEN"))
+}
+
+// SlowlogGet returns the latest count entries of the slowlog, the latest one first.
+func (c *client) SlowlogGet(count int) ([]slowlogEntry, error) {
+	values, err := redis.Values(c.do("SLOWLOG", "GET", count))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]slowlogEntry, 0, len(values))
+	for _, value := range values {
+		// id, timestamp, duration in microseconds, arguments, and the client of redis 4.0+ which are ignored.
+		fields, err := redis.Values(value, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("invalid slowlog entry with %d fields", len(fields))
+		}
+
+		id, err := redis.Int64(fields[0], nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid slowlog entry id. err:%s", err.Error())
+		}
+		usec, err := redis.Int64(fields[2], nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid slowlog entry duration. err:%s", err.Error())
+		}
+		args, err := redis.Strings(fields[3], nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid slowlog entry arguments. err:%s", err.Error())
+		}
+
+		entry := slowlogEntry{id: id, duration: time.Duration(usec) * time.Microsecond}
+		if len(args) > 0 {
+			entry.command = strings.ToLower(args[0])
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
 }
 
+// Del is not bounded by the deadline of ctx, since it cleans up the keys written before the deadline.
 func (c *client) Del(keys ...string) (int, error) {
 	ikeys := make([]interface{}, 0, len(keys))
 	for _, k := range keys {
@@ -94,39 +229,39 @@ func (c *client) Del(keys ...string) (int, error) {
 }
 
 func (c *client) Set(key, value string) (string, error) {
-	return redis.String(c.conn.Do("SET", key, value))
+	return redis.String(c.do("SET", key, value))
 }
 
 func (c *client) Hset(key, field, value string) (int, error) {
-	return redis.Int(c.conn.Do("HSET", key, field, value))
+	return redis.Int(c.do("HSET", key, field, value))
 }
 
 func (c *client) Hget(key, field string) (string, error) {
-	return redis.String(c.conn.Do("HGET", key, field))
+	return redis.String(c.do("HGET", key, field))
 }
 
 func (c *client) Lpush(key, element string) (int, error) {
-	return redis.Int(c.conn.Do("LPUSH", key, element))
+	return redis.Int(c.do("LPUSH", key, element))
 }
 
 func (c *client) Lrange(key string, start, stop int) ([]interface{}, error) {
-	return redis.Values(c.conn.Do("LRANGE", key, start, stop))
+	return redis.Values(c.do("LRANGE", key, start, stop))
 }
 
 func (c *client) Zadd(key string, score float64, member string) (int, error) {
-	return redis.Int(c.conn.Do("ZADD", key, score, member))
+	return redis.Int(c.do("ZADD", key, score, member))
 }
 
 func (c *client) Zcard(key string) (int, error) {
-	return redis.Int(c.conn.Do("ZCARD", key))
+	return redis.Int(c.do("ZCARD", key))
 }
 
 func (c *client) Sadd(key, member string) (int, error) {
-	return redis.Int(c.conn.Do("SADD", key, member))
+	return redis.Int(c.do("SADD", key, member))
 }
 
 func (c *client) Scard(key string) (int, error) {
-	return redis.Int(c.conn.Do("SCARD", key))
+	return redis.Int(c.do("SCARD", key))
 }
 
 // Pika的SCAN命令，会顺序迭代当前db的快照，由于Pika允许重名五次，所以SCAN有优先输出顺序，依次为：string -> hash -> list -> zset -> set
@@ -140,7 +275,7 @@ func (c *client) Scan(keyPattern string, count int) ([]string, error) {
 		keys   []string
 	)
 	for {
-		values, err := redis.Values(c.conn.Do("SCAN", cursor, "MATCH", keyPattern, "COUNT", count))
+		values, err := redis.Values(c.do("SCAN", cursor, "MATCH", keyPattern, "COUNT", count))
 		if err != nil {
 			return keys, fmt.Errorf("error retrieving '%s' keys", keyPattern)
 		}
@@ -161,7 +296,7 @@ func (c *client) Scan(keyPattern string, count int) ([]string, error) {
 
 // Pikad的TYPE命令，由于Pika允许重名五次，所以TYPE有优先输出顺序，依次为：string -> hash -> list -> zset -> set，如果这个key在string中存在，那么只输出sting，如果不存在，那么则输出hash的，依次类推
 func (c *client) Type(key string) (*keyInfo, error) {
-	keyType, err := redis.String(c.conn.Do("TYPE", key))
+	keyType, err := redis.String(c.do("TYPE", key))
 	if err != nil {
 		return nil, err
 	}
@@ -171,23 +306,23 @@ func (c *client) Type(key string) (*keyInfo, error) {
 	case keyTypeNone:
 		return nil, errNotFound
 	case keyTypeString:
-		if size, err := redis.Int64(c.conn.Do("STRLEN", key)); err == nil {
+		if size, err := redis.Int64(c.do("STRLEN", key)); err == nil {
 			info.size = float64(size)
 		}
 	case keyTypeList:
-		if size, err := redis.Int64(c.conn.Do("LLEN", key)); err == nil {
+		if size, err := redis.Int64(c.do("LLEN", key)); err == nil {
 			info.size = float64(size)
 		}
 	case keyTypeSet:
-		if size, err := redis.Int64(c.conn.Do("SCARD", key)); err == nil {
+		if size, err := redis.Int64(c.do("SCARD", key)); err == nil {
 			info.size = float64(size)
 		}
 	case keyTypeZSet:
-		if size, err := redis.Int64(c.conn.Do("ZCARD", key)); err == nil {
+		if size, err := redis.Int64(c.do("ZCARD", key)); err == nil {
 			info.size = float64(size)
 		}
 	case keyTypeHash:
-		if size, err := redis.Int64(c.conn.Do("HLEN", key)); err == nil {
+		if size, err := redis.Int64(c.do("HLEN", key)); err == nil {
 			info.size = float64(size)
 		}
 	default:
@@ -198,5 +333,5 @@ func (c *client) Type(key string) (*keyInfo, error) {
 }
 
 func (c *client) Get(key string) (string, error) {
-	return redis.String(c.conn.Do("GET", key))
+	return redis.String(c.do("GET", key))
 }
@@ -0,0 +1,145 @@
+package exporter
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultClientListInterval = time.Minute
+	defaultClientListTopN     = 10
+
+	clientListOtherIP = "other"
+)
+
+// clientIdleBuckets are the buckets of the idle time of the clients in seconds, 1s ~ 1d.
+var clientIdleBuckets = []float64{1, 10, 60, 300, 600, 1800, 3600, 21600, 86400}
+
+// clientListStats is the aggregation of CLIENT LIST of an instance.
+type clientListStats struct {
+	time        time.Time
+	ips         map[string]int
+	dbs         map[string]int
+	cmds        map[string]int
+	idleCount   uint64
+	idleSum     float64
+	idleBuckets map[float64]uint64
+}
+
+// parseClientList aggregates the clients of CLIENT LIST, each line of which is a client such as
+// `addr=127.0.0.1:52555 fd=8 idle=0`. The db and cmd are only in the newer versions.
+func parseClientList(list string) *clientListStats {
+	s := &clientListStats{
+		ips:         make(map[string]int),
+		dbs:         make(map[string]int),
+		cmds:        make(map[string]int),
+		idleBuckets: make(map[float64]uint64, len(clientIdleBuckets)),
+	}
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		fields := make(map[string]string)
+		for _, field := range strings.Fields(line) {
+			if pos := strings.Index(field, "="); pos > 0 {
+				fields[field[:pos]] = field[pos+1:]
+			}
+		}
+
+		if addr, ok := fields["addr"]; ok {
+			ip := addr
+			if host, _, err := net.SplitHostPort(addr); err == nil {
+				ip = host
+			}
+			s.ips[ip]++
+		}
+		if db, ok := fields["db"]; ok {
+			s.dbs["db"+db]++
+		}
+		if cmd, ok := fields["cmd"]; ok && cmd != "" {
+			s.cmds[strings.ToLower(cmd)]++
+		}
+		if idle, err := strconv.ParseFloat(fields["idle"], 64); err == nil {
+			s.idleCount++
+			s.idleSum += idle
+			for _, upperBound := range clientIdleBuckets {
+				if idle <= upperBound {
+					s.idleBuckets[upperBound]++
+				}
+			}
+		}
+	}
+	return s
+}
+
+// topIPs returns the count of the clients of the n IPs with the most clients, the clients of the others
+// are counted by the IP other, so that the cardinality is at most n+1.
+func (s *clientListStats) topIPs(n int) map[string]int {
+	ips := make([]string, 0, len(s.ips))
+	for ip := range s.ips {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		if s.ips[ips[i]] != s.ips[ips[j]] {
+			return s.ips[ips[i]] > s.ips[ips[j]]
+		}
+		return ips[i] < ips[j]
+	})
+
+	top := make(map[string]int)
+	for i, ip := range ips {
+		if i < n {
+			top[ip] = s.ips[ip]
+		} else {
+			top[clientListOtherIP] += s.ips[ip]
+		}
+	}
+	return top
+}
+
+// collectClientList aggregates CLIENT LIST of the instance at most once every clientListInterval, the last
+// aggregation is exported by the scrapes in between.
+func (e *exporter) collectClientList(c *client, im *instanceMetrics) ([]prometheus.Metric, error) {
+	k := futureKey{addr: c.Addr(), alias: c.Alias()}
+
+	e.clientListMutex.Lock()
+	s, ok := e.clientLists[k]
+	e.clientListMutex.Unlock()
+
+	if !ok || time.Since(s.time) >= e.clientListInterval {
+		list, err := c.ClientList()
+		if err != nil {
+			return nil, err
+		}
+		s = parseClientList(list)
+		s.time = time.Now()
+
+		e.clientListMutex.Lock()
+		e.clientLists[k] = s
+		e.clientListMutex.Unlock()
+	}
+
+	var promMetrics []prometheus.Metric
+	for ip, n := range s.topIPs(e.clientListTopN) {
+		promMetrics = append(promMetrics, prometheus.MustNewConstMetric(im.clientIPs, prometheus.GaugeValue,
+			float64(n), im.labelValues(k.addr, k.alias, ip)...))
+	}
+	for db, n := range s.dbs {
+		promMetrics = append(promMetrics, prometheus.MustNewConstMetric(im.clientDBs, prometheus.GaugeValue,
+			float64(n), im.labelValues(k.addr, k.alias, db)...))
+	}
+	for cmd, n := range s.cmds {
+		promMetrics = append(promMetrics, prometheus.MustNewConstMetric(im.clientCmds, prometheus.GaugeValue,
+			float64(n), im.labelValues(k.addr, k.alias, cmd)...))
+	}
+	promMetrics = append(promMetrics, prometheus.MustNewConstHistogram(im.clientIdle, s.idleCount, s.idleSum,
+		s.idleBuckets, im.labelValues(k.addr, k.alias)...))
+	return promMetrics, nil
+}
@@ -0,0 +1,88 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pourer/pika_exporter/discovery"
+	"github.com/pourer/pika_exporter/internal/respserver"
+	"github.com/stretchr/testify/assert"
+)
+
+const clientList = `addr=10.0.0.1:50001 fd=10 name= age=100 idle=0 db=0 cmd=get
+addr=10.0.0.1:50002 fd=11 name= age=100 idle=5 db=0 cmd=get
+addr=10.0.0.1:50003 fd=12 name= age=100 idle=120 db=1 cmd=set
+addr=10.0.0.2:50001 fd=13 name= age=100 idle=3600 db=0 cmd=info
+addr=10.0.0.2:50002 fd=14 name= age=100 idle=0 db=0 cmd=get
+addr=10.0.0.3:50001 fd=15 name= age=100 idle=0 db=0 cmd=client
+addr=[::1]:50001 fd=16 name= age=100 idle=0 db=0 cmd=ping
+`
+
+func TestParseClientList(t *testing.T) {
+	assert := assert.New(t)
+
+	s := parseClientList(clientList)
+	assert.Equal(map[string]int{"10.0.0.1": 3, "10.0.0.2": 2, "10.0.0.3": 1, "::1": 1}, s.ips)
+	assert.Equal(map[string]int{"db0": 6, "db1": 1}, s.dbs)
+	assert.Equal(map[string]int{"get": 3, "set": 1, "info": 1, "client": 1, "ping": 1}, s.cmds)
+	assert.Equal(uint64(7), s.idleCount)
+	assert.Equal(3725.0, s.idleSum)
+	assert.Equal(uint64(5), s.idleBuckets[10])
+	assert.Equal(uint64(6), s.idleBuckets[300])
+
+	assert.Equal(map[string]int{"10.0.0.1": 3, "10.0.0.2": 2, clientListOtherIP: 2}, s.topIPs(2))
+	assert.Equal(s.ips, s.topIPs(10))
+
+	// the clients of the older versions have no db and cmd
+	s = parseClientList("addr=10.0.0.1:50001 fd=10 idle=3\n")
+	assert.Equal(map[string]int{"10.0.0.1": 1}, s.ips)
+	assert.Empty(s.dbs)
+	assert.Empty(s.cmds)
+}
+
+func TestExporter_ClientList(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		mu    sync.Mutex
+		calls int
+	)
+	s := respserver.New(t, func(args []string) interface{} {
+		if len(args) == 2 && strings.ToUpper(args[0]) == "CLIENT" && strings.ToUpper(args[1]) == "LIST" {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			return clientList
+		}
+		return respserver.Status("OK")
+	})
+	defer s.Close()
+
+	e, err := newExporter(discovery.NewStaticDiscovery(discovery.Instance{Addr: s.Addr()}),
+		Options{Namespace: "pika", Collectors: []string{CollectorClients}, ClientListInterval: time.Hour, ClientListTopN: 2})
+	assert.NoError(err)
+	defer e.Close()
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		e.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		assert.Equal(200, w.Code)
+
+		body := w.Body.String()
+		labels := `addr="` + s.Addr() + `",alias=""`
+		assert.Contains(body, `pika_client_connections{`+labels+`,ip="10.0.0.1"} 3`)
+		assert.Contains(body, `pika_client_connections{`+labels+`,ip="other"} 2`)
+		assert.NotContains(body, `ip="10.0.0.3"`)
+		assert.Contains(body, `pika_client_connections_by_db{`+labels+`,db="db1"} 1`)
+		assert.Contains(body, `pika_client_connections_by_cmd{`+labels+`,cmd="get"} 3`)
+		assert.Contains(body, `pika_client_idle_seconds_count{`+labels+`} 7`)
+	}
+
+	// the second scrape exports the last result within the interval
+	mu.Lock()
+	assert.Equal(1, calls)
+	mu.Unlock()
+}
@@ -0,0 +1,43 @@
+package exporter
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectConfig exports the parameters of CONFIG GET, the numeric values are exported by config_value, and the
+// others by config_info with the value as the label.
+func (e *exporter) collectConfig(c *client, im *instanceMetrics, opt *instanceOptions) ([]prometheus.Metric, error) {
+	values := make(map[string]string)
+	// pika only accepts one parameter or pattern for each CONFIG GET
+	for _, parameter := range opt.configParameters {
+		kvs, err := c.ConfigGet(parameter)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range kvs {
+			values[k] = v
+		}
+	}
+
+	parameters := make([]string, 0, len(values))
+	for parameter := range values {
+		parameters = append(parameters, parameter)
+	}
+	sort.Strings(parameters)
+
+	promMetrics := make([]prometheus.Metric, 0, len(parameters))
+	for _, parameter := range parameters {
+		v := values[parameter]
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			promMetrics = append(promMetrics, prometheus.MustNewConstMetric(im.configValue, prometheus.GaugeValue, n,
+				im.labelValues(c.Addr(), c.Alias(), parameter)...))
+		} else {
+			promMetrics = append(promMetrics, prometheus.MustNewConstMetric(im.configInfo, prometheus.GaugeValue, 1,
+				im.labelValues(c.Addr(), c.Alias(), parameter, v)...))
+		}
+	}
+	return promMetrics, nil
+}
@@ -0,0 +1,52 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pourer/pika_exporter/discovery"
+	"github.com/pourer/pika_exporter/internal/respserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExporter_Config(t *testing.T) {
+	assert := assert.New(t)
+
+	configs := map[string]string{
+		"maxclients":      "20000",
+		"thread-num":      "8",
+		"slave-read-only": "yes",
+		"write-binlog":    "no",
+	}
+	s := respserver.New(t, func(args []string) interface{} {
+		if len(args) != 3 || strings.ToUpper(args[0]) != "CONFIG" || strings.ToUpper(args[1]) != "GET" {
+			return respserver.Status("OK")
+		}
+		if v, ok := configs[args[2]]; ok {
+			return []string{args[2], v}
+		}
+		return []string{}
+	})
+	defer s.Close()
+
+	e, err := newExporter(discovery.NewStaticDiscovery(discovery.Instance{Addr: s.Addr(), Alias: "a"}),
+		Options{Namespace: "pika", Collectors: []string{CollectorConfig}})
+	assert.NoError(err)
+	defer e.Close()
+
+	w := httptest.NewRecorder()
+	e.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(200, w.Code)
+
+	body := w.Body.String()
+	labels := `addr="` + s.Addr() + `",alias="a"`
+	assert.Contains(body, `pika_config_value{`+labels+`,parameter="maxclients"} 20000`)
+	assert.Contains(body, `pika_config_value{`+labels+`,parameter="thread-num"} 8`)
+	assert.Contains(body, `pika_config_info{`+labels+`,parameter="slave-read-only",value="yes"} 1`)
+	assert.Contains(body, `pika_config_info{`+labels+`,parameter="write-binlog",value="no"} 1`)
+	// the unknown parameters of the version are left out
+	assert.NotContains(body, `parameter="max-cache-files"`)
+	assert.Contains(body, `pika_exporter_scrape_count{`+labels+`} 1`)
+	assert.NotContains(body, "pika_exporter_scrape_errors{")
+}
@@ -0,0 +1,75 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+	// scrapeTimeoutOffset is left to encode and write the response before Prometheus gives up.
+	scrapeTimeoutOffset = 500 * time.Millisecond
+)
+
+// scrapeContext returns the context of the request, with the deadline of the scrape timeout of Prometheus
+// if the request has the header X-Prometheus-Scrape-Timeout-Seconds.
+func scrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	header := r.Header.Get(scrapeTimeoutHeader)
+	if header == "" {
+		return context.WithCancel(r.Context())
+	}
+
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil || seconds <= 0 {
+		log.Warnf("scrapeContext invalid header %s:%s", scrapeTimeoutHeader, header)
+		return context.WithCancel(r.Context())
+	}
+
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > scrapeTimeoutOffset {
+		timeout -= scrapeTimeoutOffset
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// contextCollector collects the exporter until the deadline of ctx.
+type contextCollector struct {
+	e   *exporter
+	ctx context.Context
+}
+
+func (c *contextCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.e.Describe(ch)
+}
+
+func (c *contextCollector) Collect(ch chan<- prometheus.Metric) {
+	c.e.collectContext(c.ctx, ch)
+}
+
+// Handler returns the handler of the exporter and the other collectors, the exporter returns the metrics
+// of the instances finished before the scrape timeout of Prometheus, instead of failing the whole response.
+func (e *exporter) Handler(collectors ...prometheus.Collector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := scrapeContext(r)
+		defer cancel()
+
+		registry := prometheus.NewRegistry()
+		if err := registry.Register(&contextCollector{e: e, ctx: ctx}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, collector := range collectors {
+			if err := registry.Register(collector); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,105 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pourer/pika_exporter/discovery"
+	"github.com/pourer/pika_exporter/internal/respserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExporter_HandlerScrapeTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	fast := respserver.New(t, func(args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "SET":
+			return respserver.Status("OK")
+		case "GET", "HGET":
+			return "value"
+		case "LRANGE":
+			return []string{"value"}
+		}
+		return 1
+	})
+	defer fast.Close()
+
+	release := make(chan struct{})
+	slow := respserver.New(t, func(args []string) interface{} {
+		<-release
+		return respserver.Status("OK")
+	})
+	defer slow.Close()
+	defer close(release)
+
+	e, err := newExporter(discovery.NewStaticDiscovery(discovery.Instance{Addr: fast.Addr()}, discovery.Instance{Addr: slow.Addr()}),
+		Options{Namespace: "pika", Collectors: []string{CollectorPing}})
+	assert.NoError(err)
+	defer e.Close()
+
+	r := httptest.NewRequest("GET", "/metrics", nil)
+	r.Header.Set(scrapeTimeoutHeader, "1")
+	w := httptest.NewRecorder()
+
+	startTime := time.Now()
+	e.Handler().ServeHTTP(w, r)
+	assert.True(time.Since(startTime) < 2*time.Second)
+
+	body := w.Body.String()
+	assert.Equal(200, w.Code)
+	assert.Contains(body, `pika_scrape_timeout{addr="`+fast.Addr()+`",alias=""} 0`)
+	assert.Contains(body, `pika_scrape_timeout{addr="`+slow.Addr()+`",alias=""} 1`)
+	assert.Contains(body, `pika_up{addr="`+fast.Addr()+`",alias=""} 1`)
+	assert.NotContains(body, `pika_ping{addr="`+slow.Addr()+`"`)
+}
+
+func TestExporter_ScrapeConcurrency(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		mu             sync.Mutex
+		active, maxAct int
+	)
+	handler := func(args []string) interface{} {
+		if strings.ToUpper(args[0]) == "SET" {
+			mu.Lock()
+			if active++; active > maxAct {
+				maxAct = active
+			}
+			mu.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mu.Lock()
+			active--
+			mu.Unlock()
+			return respserver.Status("OK")
+		}
+		return 1
+	}
+
+	var instances []discovery.Instance
+	for i := 0; i < 3; i++ {
+		s := respserver.New(t, handler)
+		defer s.Close()
+		instances = append(instances, discovery.Instance{Addr: s.Addr()})
+	}
+
+	e, err := newExporter(discovery.NewStaticDiscovery(instances...),
+		Options{Namespace: "pika", Collectors: []string{CollectorPing}, ScrapeConcurrency: 1})
+	assert.NoError(err)
+	defer e.Close()
+
+	w := httptest.NewRecorder()
+	e.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(200, w.Code)
+	assert.Equal(1, maxAct)
+	assert.Contains(w.Body.String(), "pika_exporter_scrape_queue_wait_seconds_count 3")
+	for _, instance := range instances {
+		assert.Contains(w.Body.String(), `pika_up{addr="`+instance.Addr+`",alias=""} 1`)
+	}
+}
@@ -0,0 +1,140 @@
+package exporter
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pourer/pika_exporter/discovery"
+	"github.com/pourer/pika_exporter/exporter/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var labelNameReg = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedLabelNames are the label names of the exporter's own metrics, the instance labels
+// with these names are dropped.
+var reservedLabelNames = map[string]bool{
+	metrics.LabelNameAddr:   true,
+	metrics.LabelNameAlias:  true,
+	metrics.LabelNameMethod: true,
+	metrics.LabelNameType:   true,
+	"error":                 true,
+	"db":                    true,
+	"key":                   true,
+	"key_value":             true,
+	"key_type":              true,
+	"command":               true,
+	"parameter":             true,
+	"value":                 true,
+	"ip":                    true,
+	"cmd":                   true,
+	"master_addr":           true,
+	"le":                    true,
+	"quantile":              true,
+}
+
+// instanceLabelNames returns the sorted union of the valid label names of the instances.
+func instanceLabelNames(instances []discovery.Instance) []string {
+	set := make(map[string]struct{})
+	for _, instance := range instances {
+		for name := range instance.Labels {
+			if reservedLabelNames[name] || !labelNameReg.MatchString(name) || strings.HasPrefix(name, "__") {
+				log.Warnf("instanceLabelNames invalid label name dropped. addr:%s labelName:%s", instance.Addr, name)
+				continue
+			}
+			set[name] = struct{}{}
+		}
+	}
+
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// instanceLabelValues returns the values of labels in the order of names, the missing ones are empty.
+func instanceLabelValues(names []string, labels map[string]string) []string {
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = labels[name]
+	}
+	return values
+}
+
+// infoDesc is the descriptor of the metrics of an INFO metadata with the instance labels appended,
+// the instance labels with the same names as the metadata's own labels are skipped.
+type infoDesc struct {
+	desc *prometheus.Desc
+	// instanceLabels are the indexes of the instance labels appended.
+	instanceLabels []int
+}
+
+func newInfoDesc(namespace string, m metrics.MetaData, names []string) *infoDesc {
+	labels := make([]string, len(m.Labels), len(m.Labels)+len(names))
+	copy(labels, m.Labels)
+	d := &infoDesc{}
+	for i, name := range names {
+		if containsString(m.Labels, name) {
+			continue
+		}
+		labels = append(labels, name)
+		d.instanceLabels = append(d.instanceLabels, i)
+	}
+	d.desc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", m.Name), m.Help, labels, nil)
+	return d
+}
+
+// labelValues appends the values of the instance labels to the label values of the metric.
+func (d *infoDesc) labelValues(labelValues, values []string) []string {
+	if len(d.instanceLabels) == 0 {
+		return labelValues
+	}
+
+	newLabelValues := make([]string, len(labelValues), len(labelValues)+len(d.instanceLabels))
+	copy(newLabelValues, labelValues)
+	for _, i := range d.instanceLabels {
+		var v string
+		if i < len(values) {
+			v = values[i]
+		}
+		newLabelValues = append(newLabelValues, v)
+	}
+	return newLabelValues
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalInstances(a, b map[futureKey][]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, labelValues := range a {
+		if other, ok := b[k]; !ok || !equalStrings(labelValues, other) {
+			return false
+		}
+	}
+	return true
+}
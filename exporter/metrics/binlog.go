@@ -2,6 +2,9 @@ package metrics
 
 import "regexp"
 
+// safetyPurgeTransform converts safety_purge such as write2file38101 to the file num, none is left out.
+var safetyPurgeTransform = &regexTransform{reg: regexp.MustCompile(`^write2file(\d+)$`)}
+
 func init() {
 	Register(collectBinlogMetrics)
 }
@@ -42,6 +45,14 @@ var collectBinlogMetrics = map[string]MetricConfig{
 				Labels:    []string{LabelNameAddr, LabelNameAlias, "safety_purge", "expire_logs_days", "expire_logs_nums"},
 				ValueName: "binlog_offset",
 			},
+			{
+				Name:      "binlog_safety_purge_filenum",
+				Help:      "pika serve instance binlog file num which is safe to purge",
+				Type:      metricTypeGauge,
+				Labels:    []string{LabelNameAddr, LabelNameAlias},
+				ValueName: "safety_purge",
+				Transform: safetyPurgeTransform,
+			},
 		},
 	},
 
@@ -79,6 +90,14 @@ var collectBinlogMetrics = map[string]MetricConfig{
 				Labels:    []string{LabelNameAddr, LabelNameAlias, "db", "safety_purge"},
 				ValueName: "binlog_offset",
 			},
+			{
+				Name:      "binlog_safety_purge_filenum_db",
+				Help:      "pika serve instance binlog file num which is safe to purge for each db",
+				Type:      metricTypeGauge,
+				Labels:    []string{LabelNameAddr, LabelNameAlias, "db"},
+				ValueName: "safety_purge",
+				Transform: safetyPurgeTransform,
+			},
 		},
 	},
 }
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// cacheStatuses are the status of the cache since 4.0.
+var cacheStatuses = map[string]float64{
+	"None":    0,
+	"Init":    1,
+	"Ok":      2,
+	"Reset":   3,
+	"Destroy": 4,
+	"Clean":   5,
+}
+
+// percentTransform converts the percentage such as `hitratio_all:87.5%` to 87.5.
+var percentTransform = &regexTransform{reg: regexp.MustCompile(`^\s*([\d.]+)\s*%?\s*$`)}
+
+func init() {
+	Register(collectCacheMetrics)
+}
+
+// cacheMissesParser adds the misses to the keys if not in the section, which is all_cmds minus hits, since pika
+// reports the count of all the read commands to the cache instead of the misses.
+type cacheMissesParser struct {
+	Parser
+}
+
+func (p *cacheMissesParser) Parse(m MetricMeta, c Collector, opt ParseOption) {
+	// the layer is always added, so that the layers are the same as the lookup plans, see planLookups.
+	var layer map[string]string
+	if _, ok := opt.Lookup("misses"); !ok {
+		allCmds, _ := opt.Lookup("all_cmds")
+		hits, _ := opt.Lookup("hits")
+		all, err1 := strconv.ParseFloat(allCmds, 64)
+		hit, err2 := strconv.ParseFloat(hits, 64)
+		if err1 == nil && err2 == nil && all >= hit {
+			layer = map[string]string{"misses": strconv.FormatFloat(all-hit, 'f', -1, 64)}
+		}
+	}
+	p.Parser.Parse(m, c, opt.withLayer(layer))
+}
+
+var collectCacheMetrics = map[string]MetricConfig{
+	"cache>=4.0.0": {
+		Parser: &versionMatchParser{
+			verC: mustNewVersionConstraint(`>=4.0.0`),
+			Parser: &sectionParser{
+				section: "Cache",
+				Parser:  &cacheMissesParser{Parser: &normalParser{}},
+			},
+		},
+		MetricMeta: MetaDatas{
+			{
+				Name: "cache_status",
+				Help: "pika serve instance status of the cache, 0 None, 1 Init, 2 Ok, 3 Reset, 4 Destroy, " +
+					"5 Clean",
+				Type:      metricTypeGauge,
+				Labels:    []string{LabelNameAddr, LabelNameAlias},
+				ValueName: "cache_status",
+				Transform: newEnumTransform(cacheStatuses, nil),
+			},
+			{
+				Name:      "cache_db_num",
+				Help:      "pika serve instance count of the cache dbs",
+				Type:      metricTypeGauge,
+				Labels:    []string{LabelNameAddr, LabelNameAlias},
+				ValueName: "cache_db_num",
+			},
+			{
+				Name:      "cache_keys",
+				Help:      "pika serve instance total count of the keys in the cache",
+				Type:      metricTypeGauge,
+				Labels:    []string{LabelNameAddr, LabelNameAlias},
+				ValueName: "cache_keys",
+			},
+			{
+				Name:      "cache_memory",
+				Help:      "pika serve instance used memory in bytes of the cache",
+				Type:      metricTypeGauge,
+				Labels:    []string{LabelNameAddr, LabelNameAlias},
+				ValueName: "cache_memory",
+			},
+			{
+				Name:      "cache_hits",
+				Help:      "pika serve instance total count of the read commands hitting the cache",
+				Type:      metricTypeCounter,
+				Labels:    []string{LabelNameAddr, LabelNameAlias},
+				ValueName: "hits",
+			},
+			{
+				Name:      "cache_misses",
+				Help:      "pika serve instance total count of the read commands missing the cache",
+				Type:      metricTypeCounter,
+				Labels:    []string{LabelNameAddr, LabelNameAlias},
+				ValueName: "misses",
+			},
+			{
+				Name:      "cache_hitratio_all",
+				Help:      "pika serve instance percentage of the read commands hitting the cache since started",
+				Type:      metricTypeGauge,
+				Labels:    []string{LabelNameAddr, LabelNameAlias},
+				ValueName: "hitratio_all",
+				Transform: percentTransform,
+			},
+			{
+				Name:      "cache_hitratio_per_sec",
+				Help:      "pika serve instance percentage of the read commands hitting the cache in the last second",
+				Type:      metricTypeGauge,
+				Labels:    []string{LabelNameAddr, LabelNameAlias},
+				ValueName: "hitratio_per_sec",
+				Transform: percentTransform,
+			},
+		},
+	},
+}
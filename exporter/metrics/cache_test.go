@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+)
+
+const cacheInfo = `# Server
+pika_version:4.0.0
+# Cache
+cache_status:Ok
+cache_db_num:16
+cache_keys:183342
+cache_memory:104857600
+hits:8921034
+all_cmds:10195467
+hitratio_per_sec:87.49%
+hitratio_all:87.5%
+`
+
+func TestCacheMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	sections, err := ParseInfo(cacheInfo)
+	assert.NoError(err)
+	opt := ParseOption{
+		Version:  semver.MustParse("4.0.0"),
+		Extracts: sections.Keys(),
+		Sections: sections,
+	}
+
+	got := make(map[string]float64)
+	collector := CollectFunc(func(m Metric) error {
+		got[m.Name] = m.Value
+		return nil
+	})
+	mc := collectCacheMetrics["cache>=4.0.0"]
+	mc.Parse(mc, collector, opt)
+
+	assert.Equal(map[string]float64{
+		"cache_status":           2,
+		"cache_db_num":           16,
+		"cache_keys":             183342,
+		"cache_memory":           104857600,
+		"cache_hits":             8921034,
+		"cache_misses":           1274433,
+		"cache_hitratio_all":     87.5,
+		"cache_hitratio_per_sec": 87.49,
+	}, got)
+
+	// no metrics before 4.0.0
+	got = make(map[string]float64)
+	opt.Version = semver.MustParse("3.5.0")
+	mc.Parse(mc, collector, opt)
+	assert.Empty(got)
+}
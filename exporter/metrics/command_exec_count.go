@@ -10,13 +10,11 @@ var collectCommandExecCountMetrics = map[string]MetricConfig{
 	"command_exec_count": {
 		Parser: &versionMatchParser{
 			verC: mustNewVersionConstraint(`>=3.0.0`),
-			Parser: &regexParser{
-				name: "command_exec_count_all_commands",
-				reg:  regexp.MustCompile(`# Command_Exec_Count(?P<commands_count>[^#]*)`),
+			Parser: &sectionParser{
+				section: "Command_Exec_Count",
 				Parser: &regexParser{
 					name:   "command_exec_count_command",
-					source: "commands_count",
-					reg:  regexp.MustCompile(`(\r|\n)*(?P<command>[^:]+):(?P<count>[\d]*)`),
+					reg:    regexp.MustCompile(`(?m)^(?P<command>[^:\s]+):(?P<count>[\d]*)$`),
 					Parser: &normalParser{},
 				},
 			},
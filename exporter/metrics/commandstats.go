@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"regexp"
+	"strings"
+)
+
+func init() {
+	Register(collectCommandStatsMetrics)
+}
+
+// lowerCommandParser lowercases the command, which is lowercase in Commandstats but uppercase in
+// Command_Exec_Count, so that the series of a command are the same whichever section they are from.
+type lowerCommandParser struct {
+	Parser
+}
+
+func (p *lowerCommandParser) Parse(m MetricMeta, c Collector, opt ParseOption) {
+	// the layer is always added, so that the layers are the same as the lookup plans, see planLookups.
+	var layer map[string]string
+	if command, ok := opt.Lookup("command"); ok {
+		layer = map[string]string{"command": strings.ToLower(command)}
+	}
+	p.Parser.Parse(m, c, opt.withLayer(layer))
+}
+
+var commandCallsMetaData = MetaData{
+	Name:      "command_calls",
+	Help:      "pika serve instance the count of each command called",
+	Type:      metricTypeCounter,
+	Labels:    []string{LabelNameAddr, LabelNameAlias, "command"},
+	ValueName: "calls",
+}
+
+var collectCommandStatsMetrics = map[string]MetricConfig{
+	"commandstats": {
+		Parser: &sectionParser{
+			section: "Commandstats",
+			Parser: &regexParser{
+				name: "commandstats_command",
+				reg: regexp.MustCompile(`(?m)^(?:cmdstat_)?(?P<command>[^:\s]+):\s*calls=(?P<calls>[\d]+),\s*` +
+					`usec=(?P<usec>[\d.]+),\s*usec_per_call=(?P<usec_per_call>[\d.]+)`),
+				Parser: &lowerCommandParser{Parser: &normalParser{}},
+			},
+		},
+		MetricMeta: MetaDatas{
+			commandCallsMetaData,
+			{
+				Name:      "command_usec",
+				Help:      "pika serve instance the total microseconds of each command called",
+				Type:      metricTypeCounter,
+				Labels:    []string{LabelNameAddr, LabelNameAlias, "command"},
+				ValueName: "usec",
+			},
+			{
+				Name:      "command_usec_per_call",
+				Help:      "pika serve instance the average microseconds per call of each command",
+				Type:      metricTypeGauge,
+				Labels:    []string{LabelNameAddr, LabelNameAlias, "command"},
+				ValueName: "usec_per_call",
+			},
+		},
+	},
+
+	// the calls are the counts of Command_Exec_Count for the versions without Commandstats.
+	"commandstats_command_exec_count": {
+		Parser: &versionMatchParser{
+			verC: mustNewVersionConstraint(`>=3.0.0`),
+			Parser: &keyMatchParser{
+				condition: &notCondition{Condition: &sectionCondition{section: "Commandstats"}},
+				Parser: &sectionParser{
+					section: "Command_Exec_Count",
+					Parser: &regexParser{
+						name:   "commandstats_command_exec_count_command",
+						reg:    regexp.MustCompile(`(?m)^(?P<command>[^:\s]+):(?P<calls>[\d]*)$`),
+						Parser: &lowerCommandParser{Parser: &normalParser{}},
+					},
+				},
+			},
+		},
+		MetricMeta: commandCallsMetaData,
+	},
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandStatsMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		name string
+		info string
+		want map[string]float64
+	}{
+		{
+			name: "commandstats",
+			info: `# Command_Exec_Count
+GET:10
+# Commandstats
+cmdstat_get:calls=12,usec=30,usec_per_call=2.50
+cmdstat_SET:calls=1,usec=4,usec_per_call=4.00,rejected_calls=0,failed_calls=0
+`,
+			want: map[string]float64{
+				"command_calls get":         12,
+				"command_usec get":          30,
+				"command_usec_per_call get": 2.5,
+				"command_calls set":         1,
+				"command_usec set":          4,
+				"command_usec_per_call set": 4,
+			},
+		},
+		{
+			name: "command_exec_count",
+			info: `# Command_Exec_Count
+GET:10
+SET:2
+`,
+			want: map[string]float64{
+				"command_calls get": 10,
+				"command_calls set": 2,
+			},
+		},
+	}
+	for _, c := range cases {
+		sections, err := ParseInfo(c.info)
+		assert.NoError(err, c.name)
+		opt := ParseOption{
+			Version:  semver.MustParse("3.5.0"),
+			Extracts: sections.Keys(),
+			Info:     c.info,
+			Sections: sections,
+		}
+
+		got := make(map[string]float64)
+		collector := CollectFunc(func(m Metric) error {
+			got[m.Name+" "+m.LabelValues[2]] = m.Value
+			return nil
+		})
+		for _, mc := range collectCommandStatsMetrics {
+			mc.Parse(mc, collector, opt)
+		}
+		assert.Equal(c.want, got, c.name)
+	}
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// declaration is where a metric name is declared first, the metrics with the same name must be declared
+// with the same labels, type and help, otherwise Prometheus fails the whole scrape.
+type declaration struct {
+	config string
+	MetaData
+}
+
+// CheckConflicts checks the metrics with the same name declared with the different label sets, types or helps
+// across the metric configs, and reports all of them.
+func CheckConflicts(mcs map[string]MetricConfig) error {
+	configNames := make([]string, 0, len(mcs))
+	for k := range mcs {
+		configNames = append(configNames, k)
+	}
+	sort.Strings(configNames)
+
+	declarations := make(map[string]declaration)
+	var conflicts []string
+	for _, k := range configNames {
+		mcs[k].Lookup(func(m MetaData) {
+			first, ok := declarations[m.Name]
+			if !ok {
+				declarations[m.Name] = declaration{config: k, MetaData: m}
+				return
+			}
+
+			if !equalLabelSets(first.Labels, m.Labels) {
+				conflicts = append(conflicts, fmt.Sprintf("metric %s: labels %v of config %s, but %v of config %s",
+					m.Name, first.Labels, first.config, m.Labels, k))
+			}
+			if first.MetricsType() != m.MetricsType() {
+				conflicts = append(conflicts, fmt.Sprintf("metric %s: type %q of config %s, but %q of config %s",
+					m.Name, first.Type, first.config, m.Type, k))
+			}
+			if first.Help != m.Help {
+				conflicts = append(conflicts, fmt.Sprintf("metric %s: help %q of config %s, but %q of config %s",
+					m.Name, first.Help, first.config, m.Help, k))
+			}
+		})
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("conflicting metric declarations:\n\t%s", strings.Join(conflicts, "\n\t"))
+	}
+	return nil
+}
+
+func equalLabelSets(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	sa := append([]string{}, a...)
+	sb := append([]string{}, b...)
+	sort.Strings(sa)
+	sort.Strings(sb)
+	for i := range sa {
+		if sa[i] != sb[i] {
+			return false
+		}
+	}
+	return true
+}
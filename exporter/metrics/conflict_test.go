@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckConflicts(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(CheckConflicts(MetricConfigs))
+
+	mcs := map[string]MetricConfig{
+		"a": {Parser: &normalParser{}, MetricMeta: &MetaData{
+			Name: "slave_lag", Help: "lag", Type: metricTypeGauge, Labels: []string{LabelNameAddr, "db"}}},
+		"b": {Parser: &normalParser{}, MetricMeta: MetaDatas{
+			{Name: "slave_lag", Help: "lag", Type: metricTypeGauge, Labels: []string{"db", LabelNameAddr}},
+			{Name: "keys", Help: "keys", Type: metricTypeGauge, Labels: []string{LabelNameAddr}},
+		}},
+	}
+	assert.NoError(CheckConflicts(mcs))
+
+	mcs["c"] = MetricConfig{Parser: &normalParser{}, MetricMeta: MetaDatas{
+		{Name: "slave_lag", Help: "lag", Type: metricTypeGauge, Labels: []string{LabelNameAddr, "slave_ip"}},
+		{Name: "keys", Help: "keys", Type: metricTypeCounter, Labels: []string{LabelNameAddr}},
+	}}
+	err := CheckConflicts(mcs)
+	if assert.Error(err) {
+		assert.Equal("conflicting metric declarations:\n"+
+			"\tmetric slave_lag: labels [addr db] of config a, but [addr slave_ip] of config c\n"+
+			"\tmetric keys: type \"gauge\" of config b, but \"counter\" of config c", err.Error())
+	}
+}
+
+func TestOverride_Conflicts(t *testing.T) {
+	assert := assert.New(t)
+
+	mcs := map[string]MetricConfig{
+		"conflicting_keys": {Parser: &normalParser{}, MetricMeta: &MetaData{
+			Name: "keys", Help: "keys", Type: metricTypeGauge, Labels: []string{LabelNameAddr}}},
+	}
+	err := Override(mcs)
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "metric keys: labels")
+	}
+	assert.NotContains(MetricConfigs, "conflicting_keys")
+}
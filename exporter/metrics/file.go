@@ -0,0 +1,569 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	parserTypeVersion  = "version"
+	parserTypeKeyMatch = "key_match"
+	parserTypeRegex    = "regex"
+	parserTypeSection  = "section"
+	parserTypeNormal   = "normal"
+)
+
+var (
+	labelNameReg    = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	intMatcherReg   = regexp.MustCompile(`^(>=|<=|>|<)\s*(-?\d+)$`)
+	floatMatcherReg = regexp.MustCompile(`^(>=|<=|>|<)\s*(-?[\d.]+([eE][-+]?\d+)?)$`)
+)
+
+// metricsFile is the YAML file of metric definitions, each one is a parser tree and the metadata of the metrics:
+//
+//	metrics:
+//	  <config name>:
+//	    parser:            # a parser, or a list of parsers
+//	      type: version    # version, section, key_match, regex or normal
+//	      constraint: ">=3.1.0"
+//	      parser:
+//	        type: key_match
+//	        match:         # all the keys match, see parseMatcher
+//	          role: slave
+//	        condition:     # optional, and, or, not, exists or match
+//	          not: {exists: master_link_status}
+//	        parser:
+//	          type: normal
+//	    meta:              # a metadata, or a list of metadata
+//	      name: <metric name>
+//	      help: <metric help>
+//	      type: gauge
+//	      labels: [addr, alias]
+//	      value_name: <the key in INFO>
+//	      transform:       # optional, size, enum, datetime or regex
+//	        type: enum
+//	        values: {connected: 1}
+//	        default: 0
+type metricsFile struct {
+	Metrics map[string]metricConfigNode `yaml:"metrics"`
+}
+
+type metricConfigNode struct {
+	Parser parserNodes `yaml:"parser"`
+	Meta   metaNodes   `yaml:"meta"`
+}
+
+type parserNode struct {
+	line int
+
+	Type       string         `yaml:"type"`
+	Name       string         `yaml:"name"`
+	Constraint string         `yaml:"constraint"`
+	Section    string         `yaml:"section"`
+	Match      matcherNodes   `yaml:"match"`
+	Condition  *conditionNode `yaml:"condition"`
+	Source     string         `yaml:"source"`
+	Regex      string         `yaml:"regex"`
+	Optional   bool           `yaml:"optional"`
+	Parser     parserNodes    `yaml:"parser"`
+}
+
+func (n *parserNode) UnmarshalYAML(value *yaml.Node) error {
+	if err := checkKeys(value, "type", "name", "constraint", "section", "match", "condition", "source", "regex", "optional", "parser"); err != nil {
+		return err
+	}
+
+	type plain parserNode
+	if err := value.Decode((*plain)(n)); err != nil {
+		return err
+	}
+	n.line = value.Line
+	return nil
+}
+
+// parserNodes is a parser, or a list of parsers which are all applied.
+type parserNodes []parserNode
+
+func (ns *parserNodes) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.SequenceNode {
+		var nodes []parserNode
+		if err := value.Decode(&nodes); err != nil {
+			return err
+		}
+		*ns = nodes
+		return nil
+	}
+
+	var node parserNode
+	if err := value.Decode(&node); err != nil {
+		return err
+	}
+	*ns = parserNodes{node}
+	return nil
+}
+
+// matcherNode is the matcher of a key, a value or a list of values in which the value is.
+type matcherNode struct {
+	line   int
+	value  string
+	values []string
+	list   bool
+}
+
+func (n *matcherNode) UnmarshalYAML(value *yaml.Node) error {
+	n.line = value.Line
+	switch value.Kind {
+	case yaml.ScalarNode:
+		return value.Decode(&n.value)
+	case yaml.SequenceNode:
+		n.list = true
+		return value.Decode(&n.values)
+	}
+	return fmt.Errorf("line %d: expected a value or a list of values", value.Line)
+}
+
+type matcherNodes map[string]matcherNode
+
+// conditionNode is one of and, or, not, exists, section and match, the conditions can be nested.
+type conditionNode struct {
+	line int
+
+	And     []conditionNode `yaml:"and"`
+	Or      []conditionNode `yaml:"or"`
+	Not     *conditionNode  `yaml:"not"`
+	Exists  string          `yaml:"exists"`
+	Section string          `yaml:"section"`
+	Match   matcherNodes    `yaml:"match"`
+}
+
+func (n *conditionNode) UnmarshalYAML(value *yaml.Node) error {
+	if err := checkKeys(value, "and", "or", "not", "exists", "section", "match"); err != nil {
+		return err
+	}
+	if len(value.Content) != 2 {
+		return fmt.Errorf("line %d: condition requires exactly one of and, or, not, exists, section and match", value.Line)
+	}
+
+	type plain conditionNode
+	if err := value.Decode((*plain)(n)); err != nil {
+		return err
+	}
+	n.line = value.Line
+	return nil
+}
+
+type metaNode struct {
+	line int
+
+	Name      string         `yaml:"name"`
+	Help      string         `yaml:"help"`
+	Type      string         `yaml:"type"`
+	Labels    []string       `yaml:"labels"`
+	ValueName string         `yaml:"value_name"`
+	Transform *transformNode `yaml:"transform"`
+}
+
+func (n *metaNode) UnmarshalYAML(value *yaml.Node) error {
+	if err := checkKeys(value, "name", "help", "type", "labels", "value_name", "transform"); err != nil {
+		return err
+	}
+
+	type plain metaNode
+	if err := value.Decode((*plain)(n)); err != nil {
+		return err
+	}
+	n.line = value.Line
+	return nil
+}
+
+type transformNode struct {
+	line int
+
+	Type     string             `yaml:"type"`
+	Values   map[string]float64 `yaml:"values"`
+	Default  *float64           `yaml:"default"`
+	Layout   string             `yaml:"layout"`
+	Timezone string             `yaml:"timezone"`
+	Regex    string             `yaml:"regex"`
+}
+
+func (n *transformNode) UnmarshalYAML(value *yaml.Node) error {
+	if err := checkKeys(value, "type", "values", "default", "layout", "timezone", "regex"); err != nil {
+		return err
+	}
+
+	type plain transformNode
+	if err := value.Decode((*plain)(n)); err != nil {
+		return err
+	}
+	n.line = value.Line
+	return nil
+}
+
+// metaNodes is a metadata, or a list of metadata parsed by the same parser.
+type metaNodes struct {
+	line  int
+	list  bool
+	nodes []metaNode
+}
+
+func (ns *metaNodes) UnmarshalYAML(value *yaml.Node) error {
+	ns.line = value.Line
+	if value.Kind == yaml.SequenceNode {
+		ns.list = true
+		return value.Decode(&ns.nodes)
+	}
+
+	var node metaNode
+	if err := value.Decode(&node); err != nil {
+		return err
+	}
+	ns.nodes = []metaNode{node}
+	return nil
+}
+
+func checkKeys(value *yaml.Node, keys ...string) error {
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping", value.Line)
+	}
+
+	for i := 0; i < len(value.Content); i += 2 {
+		key := value.Content[i]
+		found := false
+		for _, k := range keys {
+			if key.Value == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("line %d: unknown field %q", key.Line, key.Value)
+		}
+	}
+	return nil
+}
+
+// LoadFile loads the metric configs from the YAML file, see Load.
+func LoadFile(fileName string) (map[string]MetricConfig, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return Load(data)
+}
+
+// Load loads the metric configs from the YAML data of metric definitions, the parser tree and metadata
+// of each metric config are validated, and the errors are reported with the line numbers.
+func Load(data []byte) (map[string]MetricConfig, error) {
+	var file metricsFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	mcs := make(map[string]MetricConfig, len(file.Metrics))
+	for name, node := range file.Metrics {
+		if len(node.Parser) == 0 {
+			return nil, fmt.Errorf("metric config %s: parser is required", name)
+		}
+		if len(node.Meta.nodes) == 0 {
+			return nil, fmt.Errorf("metric config %s: meta is required", name)
+		}
+
+		parser, err := buildParsers(node.Parser)
+		if err != nil {
+			return nil, fmt.Errorf("metric config %s: %s", name, err.Error())
+		}
+		meta, err := buildMeta(node.Meta)
+		if err != nil {
+			return nil, fmt.Errorf("metric config %s: %s", name, err.Error())
+		}
+		mcs[name] = MetricConfig{Parser: parser, MetricMeta: meta}
+	}
+	return mcs, nil
+}
+
+func buildParsers(ns parserNodes) (Parser, error) {
+	if len(ns) == 0 {
+		return &normalParser{}, nil
+	}
+
+	parsers := make(Parsers, len(ns))
+	for i, n := range ns {
+		p, err := buildParser(n)
+		if err != nil {
+			return nil, err
+		}
+		parsers[i] = p
+	}
+	if len(parsers) == 1 {
+		return parsers[0], nil
+	}
+	return parsers, nil
+}
+
+func buildParser(n parserNode) (Parser, error) {
+	if n.Type == parserTypeNormal {
+		if len(n.Parser) > 0 {
+			return nil, fmt.Errorf("line %d: normal parser has no child parser", n.line)
+		}
+		return &normalParser{}, nil
+	}
+
+	child, err := buildParsers(n.Parser)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Type {
+	case parserTypeVersion:
+		verC, err := semver.NewConstraint(n.Constraint)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid version constraint %q: %s", n.line, n.Constraint, err.Error())
+		}
+		return &versionMatchParser{verC: verC, Parser: child}, nil
+	case parserTypeSection:
+		if n.Section == "" {
+			return nil, fmt.Errorf("line %d: section parser requires section", n.line)
+		}
+		return &sectionParser{section: n.Section, Parser: child}, nil
+	case parserTypeKeyMatch:
+		if len(n.Match) == 0 && n.Condition == nil {
+			return nil, fmt.Errorf("line %d: key_match parser requires match or condition", n.line)
+		}
+		matchers, err := buildMatchers(n.Match)
+		if err != nil {
+			return nil, err
+		}
+		p := &keyMatchParser{matchers: matchers, Parser: child}
+		if n.Condition != nil {
+			if p.condition, err = buildCondition(n.Condition); err != nil {
+				return nil, err
+			}
+		}
+		return p, nil
+	case parserTypeRegex:
+		if n.Regex == "" {
+			return nil, fmt.Errorf("line %d: regex parser requires regex", n.line)
+		}
+		reg, err := regexp.Compile(n.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid regex %q: %s", n.line, n.Regex, err.Error())
+		}
+		return &regexParser{name: n.Name, source: n.Source, reg: reg, optional: n.Optional, Parser: child}, nil
+	case "":
+		return nil, fmt.Errorf("line %d: parser type is required", n.line)
+	}
+	return nil, fmt.Errorf("line %d: unknown parser type %q", n.line, n.Type)
+}
+
+func buildMatchers(ns matcherNodes) (map[string]Matcher, error) {
+	matchers := make(map[string]Matcher, len(ns))
+	for key, n := range ns {
+		if !n.list {
+			matcher, err := parseMatcher(n.value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", n.line, err.Error())
+			}
+			matchers[key] = matcher
+			continue
+		}
+
+		if len(n.values) == 0 {
+			return nil, fmt.Errorf("line %d: empty list of values of %s", n.line, key)
+		}
+		matchers[key] = &inMatcher{values: n.values}
+	}
+	return matchers, nil
+}
+
+// parseMatcher parses the value of match:
+//
+//	>1, <=1.5     compared as a number by the leading >, <, >= or <=
+//	=~regex       matches the regex, which is anchored at both ends
+//	!~regex       not matches the regex
+//	!=value       not equals to the value case-insensitively
+//	value         equals to the value case-insensitively
+func parseMatcher(v string) (Matcher, error) {
+	if matches := intMatcherReg.FindStringSubmatch(v); matches != nil {
+		n, _ := strconv.Atoi(matches[2])
+		return &intMatcher{condition: matches[1], v: n}, nil
+	}
+	if matches := floatMatcherReg.FindStringSubmatch(v); matches != nil {
+		n, err := strconv.ParseFloat(matches[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", v)
+		}
+		return &floatMatcher{condition: matches[1], v: n}, nil
+	}
+
+	switch {
+	case strings.HasPrefix(v, "=~"), strings.HasPrefix(v, "!~"):
+		reg, err := regexp.Compile("^(?:" + v[2:] + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %s", v[2:], err.Error())
+		}
+		if strings.HasPrefix(v, "!~") {
+			return &notMatcher{Matcher: &regexMatcher{reg: reg}}, nil
+		}
+		return &regexMatcher{reg: reg}, nil
+	case strings.HasPrefix(v, "!="):
+		return &notEqualMatcher{v: v[2:]}, nil
+	}
+	return &equalMatcher{v: v}, nil
+}
+
+func buildCondition(n *conditionNode) (Condition, error) {
+	switch {
+	case len(n.And) > 0, len(n.Or) > 0:
+		nodes := n.And
+		if len(n.Or) > 0 {
+			nodes = n.Or
+		}
+		cs := make([]Condition, len(nodes))
+		for i := range nodes {
+			c, err := buildCondition(&nodes[i])
+			if err != nil {
+				return nil, err
+			}
+			cs[i] = c
+		}
+		if len(n.Or) > 0 {
+			return orCondition(cs), nil
+		}
+		return andCondition(cs), nil
+	case n.Not != nil:
+		c, err := buildCondition(n.Not)
+		if err != nil {
+			return nil, err
+		}
+		return &notCondition{Condition: c}, nil
+	case n.Exists != "":
+		return &existsCondition{key: n.Exists}, nil
+	case n.Section != "":
+		return &sectionCondition{section: n.Section}, nil
+	case len(n.Match) > 0:
+		matchers, err := buildMatchers(n.Match)
+		if err != nil {
+			return nil, err
+		}
+		cs := make(andCondition, 0, len(matchers))
+		for key, matcher := range matchers {
+			cs = append(cs, &keyCondition{key: key, Matcher: matcher})
+		}
+		return cs, nil
+	}
+	return nil, fmt.Errorf("line %d: empty condition", n.line)
+}
+
+func buildMeta(ns metaNodes) (MetricMeta, error) {
+	ms := make(MetaDatas, len(ns.nodes))
+	for i, n := range ns.nodes {
+		if n.Name == "" || !labelNameReg.MatchString(n.Name) {
+			return nil, fmt.Errorf("line %d: invalid metric name %q", n.line, n.Name)
+		}
+		switch n.Type {
+		case "", metricTypeCounter, metricTypeGauge, metricTypeUntyped:
+		default:
+			return nil, fmt.Errorf("line %d: invalid metric type %q, valid options: counter gauge untyped", n.line, n.Type)
+		}
+		for _, label := range n.Labels {
+			if !labelNameReg.MatchString(label) {
+				return nil, fmt.Errorf("line %d: invalid label name %q", n.line, label)
+			}
+		}
+
+		var transform ValueTransform
+		if n.Transform != nil {
+			if n.ValueName == "" {
+				return nil, fmt.Errorf("line %d: transform requires value_name", n.line)
+			}
+			var err error
+			if transform, err = buildTransform(n.Transform); err != nil {
+				return nil, err
+			}
+		}
+
+		ms[i] = MetaData{
+			Name:      n.Name,
+			Help:      n.Help,
+			Type:      n.Type,
+			Labels:    n.Labels,
+			ValueName: n.ValueName,
+			Transform: transform,
+		}
+	}
+
+	if !ns.list {
+		return &ms[0], nil
+	}
+	return ms, nil
+}
+
+func buildTransform(n *transformNode) (ValueTransform, error) {
+	switch n.Type {
+	case transformTypeSize:
+		return &sizeTransform{}, nil
+	case transformTypeEnum:
+		if len(n.Values) == 0 {
+			return nil, fmt.Errorf("line %d: enum transform requires values", n.line)
+		}
+		return newEnumTransform(n.Values, n.Default), nil
+	case transformTypeDatetime:
+		location := time.Local
+		if n.Timezone != "" {
+			var err error
+			if location, err = time.LoadLocation(n.Timezone); err != nil {
+				return nil, fmt.Errorf("line %d: invalid timezone %q: %s", n.line, n.Timezone, err.Error())
+			}
+		}
+		return &datetimeTransform{layout: n.Layout, location: location}, nil
+	case transformTypeRegex:
+		reg, err := regexp.Compile(n.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid regex %q: %s", n.line, n.Regex, err.Error())
+		}
+		if reg.NumSubexp() == 0 {
+			return nil, fmt.Errorf("line %d: regex transform requires a group", n.line)
+		}
+		return &regexTransform{reg: reg}, nil
+	case "":
+		return nil, fmt.Errorf("line %d: transform type is required", n.line)
+	}
+	return nil, fmt.Errorf("line %d: unknown transform type %q, valid options: size enum datetime regex", n.line, n.Type)
+}
+
+// Override registers the metric configs loaded from file, which replace the built-in ones with the same names.
+// Nothing is registered if the metrics conflict with the others, see CheckConflicts.
+func Override(mcs map[string]MetricConfig) error {
+	merged := make(map[string]MetricConfig, len(MetricConfigs)+len(mcs))
+	for k, mc := range MetricConfigs {
+		merged[k] = mc
+	}
+	for k, mc := range mcs {
+		merged[k] = mc
+	}
+	if err := CheckConflicts(merged); err != nil {
+		return err
+	}
+
+	for k, mc := range mcs {
+		if _, ok := MetricConfigs[k]; ok {
+			log.Infof("metrics::Override metric config overridden by file. metricConfigName:%s", k)
+		}
+		MetricConfigs[k] = withIDs(mc)
+		planLookups(MetricConfigs[k])
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadFile(t *testing.T) {
+	assert := assert.New(t)
+
+	mcs, err := LoadFile("../../contrib/sample_pika_metrics_file.yml")
+	assert.NoError(err)
+	assert.Len(mcs, 5)
+
+	info := `# Replication(MASTER)
+role:master
+connected_slaves:2
+slave0:ip=10.200.14.148,port=9222,conn_fd=131,lag=(db0:10)
+slave1:ip=10.200.14.148,port=9221,conn_fd=135,lag=(db0:0)
+is_compact:Yes
+log_size_human:12M`
+	opt := ParseOption{
+		Version: semver.MustParse("3.3.5"),
+		Extracts: map[string]string{
+			LabelNameAddr:      "127.0.0.1:9221",
+			LabelNameAlias:     "",
+			"role":             "master",
+			"connected_slaves": "2",
+			"is_compact":       "Yes",
+			"log_size_human":   "12M",
+		},
+		Info: info,
+	}
+	opt.Sections, err = ParseInfo(info)
+	assert.NoError(err)
+
+	var collected []Metric
+	collector := CollectFunc(func(m Metric) error {
+		collected = append(collected, m)
+		return nil
+	})
+	for _, name := range []string{"is_compact", "master_connected_slaves", "slave_lag_of_db", "log_size_human"} {
+		mc := mcs[name]
+		mc.Parse(mc, collector, opt)
+	}
+
+	if assert.Len(collected, 5) {
+		assert.Equal("is_compact", collected[0].Name)
+		assert.Equal(1.0, collected[0].Value)
+		assert.Equal("connected_slaves", collected[1].Name)
+		assert.Equal(2.0, collected[1].Value)
+		assert.Equal([]string{"127.0.0.1:9221", "", "10.200.14.148", "9222", "db0"}, collected[2].LabelValues)
+		assert.Equal(10.0, collected[2].Value)
+		assert.Equal(0.0, collected[3].Value)
+		assert.Equal("log_size_human_bytes", collected[4].Name)
+		assert.Equal(12.0*1024*1024, collected[4].Value)
+	}
+}
+
+func TestLoad_Condition(t *testing.T) {
+	assert := assert.New(t)
+
+	mcs, err := Load([]byte(`
+metrics:
+  slave_link_not_up:
+    parser:
+      type: key_match
+      match:
+        role: [slave, single]
+      condition:
+        or:
+          - match:
+              master_link_status: "!=up"
+          - not:
+              exists: master_link_status
+      parser:
+        type: normal
+    meta:
+      name: slave_link_not_up
+      labels: [addr]`))
+	if !assert.NoError(err) {
+		return
+	}
+
+	count := 0
+	collector := CollectFunc(func(m Metric) error {
+		count++
+		return nil
+	})
+	mc := mcs["slave_link_not_up"]
+	for _, extracts := range []map[string]string{
+		{"role": "slave", "master_link_status": "up"},
+		{"role": "slave", "master_link_status": "down"},
+		{"role": "slave"},
+		{"role": "master"},
+	} {
+		mc.Parse(mc, collector, ParseOption{Extracts: extracts})
+	}
+	assert.Equal(2, count)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	cases := []struct {
+		name, data, err string
+	}{
+		{"unknown field", `
+metrics:
+  a:
+    parser:
+      type: normal
+      regexp: x
+    meta: {name: a}`, `line 6: unknown field "regexp"`},
+		{"unknown parser type", `
+metrics:
+  a:
+    parser:
+      type: json
+    meta: {name: a}`, `line 5: unknown parser type "json"`},
+		{"invalid constraint", `
+metrics:
+  a:
+    parser:
+      type: version
+      constraint: abc
+    meta: {name: a}`, `line 5: invalid version constraint "abc"`},
+		{"missing section", `
+metrics:
+  a:
+    parser:
+      type: section
+    meta: {name: a}`, `line 5: section parser requires section`},
+		{"invalid regex", `
+metrics:
+  a:
+    parser:
+      - type: regex
+        regex: "(abc"
+    meta: {name: a}`, `line 5: invalid regex "(abc"`},
+		{"missing regex", `
+metrics:
+  a:
+    parser:
+      - type: regex
+        regex: ""
+    meta: {name: a}`, `line 5: regex parser requires regex`},
+		{"invalid metric type", `
+metrics:
+  a:
+    parser: {type: normal}
+    meta: {name: a, type: summary}`, `line 5: invalid metric type "summary"`},
+		{"unknown transform type", `
+metrics:
+  a:
+    parser: {type: normal}
+    meta:
+      name: a
+      value_name: a
+      transform: {type: json}`, `line 8: unknown transform type "json"`},
+		{"transform without group", `
+metrics:
+  a:
+    parser: {type: normal}
+    meta:
+      name: a
+      value_name: a
+      transform: {type: regex, regex: "write2file"}`, `line 8: regex transform requires a group`},
+		{"invalid matcher", `
+metrics:
+  a:
+    parser:
+      type: key_match
+      match: {role: "=~(slave"}
+    meta: {name: a}`, `line 6: invalid regex "(slave"`},
+		{"missing match", `
+metrics:
+  a:
+    parser:
+      type: key_match
+    meta: {name: a}`, `line 5: key_match parser requires match or condition`},
+		{"ambiguous condition", `
+metrics:
+  a:
+    parser:
+      type: key_match
+      condition: {exists: role, not: {exists: role}}
+    meta: {name: a}`, `line 6: condition requires exactly one of`},
+		{"missing meta", `
+metrics:
+  a:
+    parser: {type: normal}`, `metric config a: meta is required`},
+	}
+
+	for _, c := range cases {
+		_, err := Load([]byte(c.data))
+		if assert.Error(t, err, c.name) {
+			assert.Contains(t, err.Error(), c.err, c.name)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"bufio"
+	"regexp"
+	"strings"
+)
+
+const keyValueSeparator = ":"
+
+// sectionHeaderReg matches the section headers such as `# Server` and `# Replication(MASTER)`, but not
+// the lines such as `# Time:2020-07-23 14:26:33` in the Keyspace section.
+var sectionHeaderReg = regexp.MustCompile(`^#\s*([A-Za-z_]+)\s*(\(([^)]*)\))?$`)
+
+// InfoSection is a section of INFO, the lines before the first header are in the section without name.
+type InfoSection struct {
+	// Name is the header without the argument, such as Replication of `# Replication(MASTER)`.
+	Name string
+	// Arg is the argument in the parentheses of the header, such as MASTER of `# Replication(MASTER)`.
+	Arg   string
+	Lines []string
+	Keys  map[string]string
+}
+
+// Text returns the lines of the section, without the header.
+func (s *InfoSection) Text() string {
+	return strings.Join(s.Lines, "\n")
+}
+
+// Sections are the sections of INFO in order, the same section may appear more than once.
+type Sections []*InfoSection
+
+// ParseInfo parses INFO into the sections, each line of a section is a key and value separated by
+// the first colon, the line without colon is the key with empty value.
+func ParseInfo(info string) (Sections, error) {
+	section := &InfoSection{Keys: make(map[string]string)}
+	sections := Sections{section}
+
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		line := trimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if matches := sectionHeaderReg.FindStringSubmatch(line); matches != nil {
+			section = &InfoSection{
+				Name: matches[1],
+				Arg:  matches[3],
+				Keys: make(map[string]string),
+			}
+			sections = append(sections, section)
+			continue
+		}
+
+		section.Lines = append(section.Lines, line)
+		k, v := fetchKV(line)
+		section.Keys[k] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return sections, nil
+}
+
+// Get returns the sections with the name case-insensitively.
+func (ss Sections) Get(name string) Sections {
+	var sections Sections
+	for _, s := range ss {
+		if strings.EqualFold(s.Name, name) {
+			sections = append(sections, s)
+		}
+	}
+	return sections
+}
+
+// Keys returns the keys of all the sections, the key of the latter section overwrites the former one.
+func (ss Sections) Keys() map[string]string {
+	keys := make(map[string]string)
+	for _, s := range ss {
+		for k, v := range s.Keys {
+			keys[k] = v
+		}
+	}
+	return keys
+}
+
+// Merge merges the sections into one, such as `# Command_Exec_Count` appearing twice in INFO of some versions.
+// The line of a key in the latter section replaces the one in the former section.
+func (ss Sections) Merge() *InfoSection {
+	if len(ss) == 1 {
+		return ss[0]
+	}
+
+	merged := &InfoSection{Keys: make(map[string]string)}
+	index := make(map[string]int)
+	for _, s := range ss {
+		if merged.Name == "" {
+			merged.Name, merged.Arg = s.Name, s.Arg
+		}
+		for _, line := range s.Lines {
+			k, v := fetchKV(line)
+			if i, ok := index[k]; ok {
+				merged.Lines[i] = line
+			} else {
+				index[k] = len(merged.Lines)
+				merged.Lines = append(merged.Lines, line)
+			}
+			merged.Keys[k] = v
+		}
+	}
+	return merged
+}
+
+func fetchKV(s string) (k, v string) {
+	pos := strings.Index(s, keyValueSeparator)
+	if pos < 0 {
+		k = s
+		return
+	}
+
+	k = trimSpace(s[:pos])
+	v = trimSpace(s[pos+1:])
+	return
+}
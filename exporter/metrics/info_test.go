@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sectionsInfo = `# Server
+pika_version:3.2.0
+# Command_Exec_Count
+GET:2
+SET:1
+# Replication(SLAVE)
+role:slave
+master_host:127.0.0.1
+# Command_Exec_Count
+GET:3
+INFO:4
+# Keyspace
+# Time:1970-01-01 08:00:00
+db0 Strings_keys=1
+`
+
+func TestParseInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	sections, err := ParseInfo(sectionsInfo)
+	assert.NoError(err)
+	assert.Len(sections, 6)
+
+	replication := sections.Get("replication")
+	if assert.Len(replication, 1) {
+		assert.Equal("Replication", replication[0].Name)
+		assert.Equal("SLAVE", replication[0].Arg)
+		assert.Equal("slave", replication[0].Keys["role"])
+	}
+
+	keyspace := sections.Get("Keyspace")
+	if assert.Len(keyspace, 1) {
+		assert.Equal([]string{"# Time:1970-01-01 08:00:00", "db0 Strings_keys=1"}, keyspace[0].Lines)
+	}
+
+	merged := sections.Get("Command_Exec_Count").Merge()
+	assert.Equal("GET:3\nSET:1\nINFO:4", merged.Text())
+	assert.Equal(map[string]string{"GET": "3", "SET": "1", "INFO": "4"}, merged.Keys)
+
+	keys := sections.Keys()
+	assert.Equal("3.2.0", keys["pika_version"])
+	assert.Equal("3", keys["GET"])
+}
+
+func TestSectionParser(t *testing.T) {
+	assert := assert.New(t)
+
+	sections, err := ParseInfo(sectionsInfo)
+	assert.NoError(err)
+	opt := ParseOption{
+		Extracts: map[string]string{LabelNameAddr: "127.0.0.1:9221", LabelNameAlias: "", "GET": "0"},
+		Info:     sectionsInfo,
+		Sections: sections,
+	}
+
+	var got ParseOption
+	p := &sectionParser{section: "Command_Exec_Count", Parser: parserFunc(func(m MetricMeta, c Collector, opt ParseOption) {
+		got = opt
+	})}
+	p.Parse(nil, nil, opt)
+	assert.Equal("GET:3\nSET:1\nINFO:4", got.Info)
+	v, _ := got.Lookup("GET")
+	assert.Equal("3", v)
+	v, _ = got.Lookup(LabelNameAddr)
+	assert.Equal("127.0.0.1:9221", v)
+	// the extracts are not modified by the parser
+	assert.Equal("0", opt.Extracts["GET"])
+
+	got = ParseOption{}
+	p.section = "Rocksdb"
+	p.Parse(nil, nil, opt)
+	assert.Nil(got.Extracts)
+}
+
+type parserFunc func(m MetricMeta, c Collector, opt ParseOption)
+
+func (f parserFunc) Parse(m MetricMeta, c Collector, opt ParseOption) {
+	f(m, c, opt)
+}
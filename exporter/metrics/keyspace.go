@@ -8,49 +8,52 @@ func init() {
 
 var collectKeySpaceMetrics = map[string]MetricConfig{
 	"keyspace_info": {
-		Parser: Parsers{
-			&versionMatchParser{
-				verC: mustNewVersionConstraint(`<3.0.5`),
-				Parser: &regexParser{
-					name:   "keyspace_info_<3.0.5",
-					reg:    regexp.MustCompile(`(?P<type>[^\s]*)\s*keys:(?P<keys>[\d]+)`),
-					Parser: &normalParser{},
+		Parser: &sectionParser{
+			section: "Keyspace",
+			Parser: Parsers{
+				&versionMatchParser{
+					verC: mustNewVersionConstraint(`<3.0.5`),
+					Parser: &regexParser{
+						name:   "keyspace_info_<3.0.5",
+						reg:    regexp.MustCompile(`(?P<type>[^\s]*)\s*keys:(?P<keys>[\d]+)`),
+						Parser: &normalParser{},
+					},
 				},
-			},
-			&versionMatchParser{
-				verC: mustNewVersionConstraint(`~3.0.5`),
-				Parser: &regexParser{
-					name: "keyspace_info_~3.0.5",
-					reg: regexp.MustCompile(`(?P<type>\w*):\s*keys=(?P<keys>[\d]+)[,\s]*` +
-						`expires=(?P<expire_keys>[\d]+)[,\s]*invaild_keys=(?P<invalid_keys>[\d]+)`),
-					Parser: &normalParser{},
+				&versionMatchParser{
+					verC: mustNewVersionConstraint(`~3.0.5`),
+					Parser: &regexParser{
+						name: "keyspace_info_~3.0.5",
+						reg: regexp.MustCompile(`(?P<type>\w*):\s*keys=(?P<keys>[\d]+)[,\s]*` +
+							`expires=(?P<expire_keys>[\d]+)[,\s]*invaild_keys=(?P<invalid_keys>[\d]+)`),
+						Parser: &normalParser{},
+					},
 				},
-			},
-			&versionMatchParser{
-				verC: mustNewVersionConstraint(`~3.1.0`),
-				Parser: &regexParser{
-					name: "keyspace_info_~3.1.0",
-					reg: regexp.MustCompile(`(?P<db>db[\d]+)_\s*(?P<type>[^:]+):\s*keys=(?P<keys>[\d]+)[,\s]*` +
-						`expires=(?P<expire_keys>[\d]+)[,\s]*invaild_keys=(?P<invalid_keys>[\d]+)`),
-					Parser: &normalParser{},
+				&versionMatchParser{
+					verC: mustNewVersionConstraint(`~3.1.0`),
+					Parser: &regexParser{
+						name: "keyspace_info_~3.1.0",
+						reg: regexp.MustCompile(`(?P<db>db[\d]+)_\s*(?P<type>[^:]+):\s*keys=(?P<keys>[\d]+)[,\s]*` +
+							`expires=(?P<expire_keys>[\d]+)[,\s]*invaild_keys=(?P<invalid_keys>[\d]+)`),
+						Parser: &normalParser{},
+					},
 				},
-			},
-			&versionMatchParser{
-				verC: mustNewVersionConstraint(`3.2.0 - 3.3.2`),
-				Parser: &regexParser{
-					name: "keyspace_info_3.1.0-3.3.2",
-					reg: regexp.MustCompile(`(?P<db>db[\d]+)\s*(?P<type>[^_]+)\w*keys=(?P<keys>[\d]+)[,\s]*` +
-						`expires=(?P<expire_keys>[\d]+)[,\s]*invaild_keys=(?P<invalid_keys>[\d]+)`),
-					Parser: &normalParser{},
+				&versionMatchParser{
+					verC: mustNewVersionConstraint(`3.2.0 - 3.3.2`),
+					Parser: &regexParser{
+						name: "keyspace_info_3.1.0-3.3.2",
+						reg: regexp.MustCompile(`(?P<db>db[\d]+)\s*(?P<type>[^_]+)\w*keys=(?P<keys>[\d]+)[,\s]*` +
+							`expires=(?P<expire_keys>[\d]+)[,\s]*invaild_keys=(?P<invalid_keys>[\d]+)`),
+						Parser: &normalParser{},
+					},
 				},
-			},
-			&versionMatchParser{
-				verC: mustNewVersionConstraint(`>=3.3.3`),
-				Parser: &regexParser{
-					name: "keyspace_info_>=3.1.0",
-					reg: regexp.MustCompile(`(?P<db>db[\d]+)\s*(?P<type>[^_]+)\w*keys=(?P<keys>[\d]+)[,\s]*` +
-						`expires=(?P<expire_keys>[\d]+)[,\s]*invalid_keys=(?P<invalid_keys>[\d]+)`),
-					Parser: &normalParser{},
+				&versionMatchParser{
+					verC: mustNewVersionConstraint(`>=3.3.3`),
+					Parser: &regexParser{
+						name: "keyspace_info_>=3.1.0",
+						reg: regexp.MustCompile(`(?P<db>db[\d]+)\s*(?P<type>[^_]+)\w*keys=(?P<keys>[\d]+)[,\s]*` +
+							`expires=(?P<expire_keys>[\d]+)[,\s]*invalid_keys=(?P<invalid_keys>[\d]+)`),
+						Parser: &normalParser{},
+					},
 				},
 			},
 		},
@@ -66,41 +69,44 @@ var collectKeySpaceMetrics = map[string]MetricConfig{
 	},
 
 	"keyspace_info_all": {
-		Parser: Parsers{
-			&versionMatchParser{
-				verC: mustNewVersionConstraint(`~3.0.5`),
-				Parser: &regexParser{
-					name: "keyspace_info_all_~3.0.5",
-					reg: regexp.MustCompile(`(?P<type>\w*):\s*keys=(?P<keys>[\d]+)[,\s]*` +
-						`expires=(?P<expire_keys>[\d]+)[,\s]*invaild_keys=(?P<invalid_keys>[\d]+)`),
-					Parser: &normalParser{},
+		Parser: &sectionParser{
+			section: "Keyspace",
+			Parser: Parsers{
+				&versionMatchParser{
+					verC: mustNewVersionConstraint(`~3.0.5`),
+					Parser: &regexParser{
+						name: "keyspace_info_all_~3.0.5",
+						reg: regexp.MustCompile(`(?P<type>\w*):\s*keys=(?P<keys>[\d]+)[,\s]*` +
+							`expires=(?P<expire_keys>[\d]+)[,\s]*invaild_keys=(?P<invalid_keys>[\d]+)`),
+						Parser: &normalParser{},
+					},
 				},
-			},
-			&versionMatchParser{
-				verC: mustNewVersionConstraint(`~3.1.0`),
-				Parser: &regexParser{
-					name: "keyspace_info_all_~3.1.0",
-					reg: regexp.MustCompile(`(?P<db>db[\d]+)_\s*(?P<type>[^:]+):\s*keys=(?P<keys>[\d]+)[,\s]*` +
-						`expires=(?P<expire_keys>[\d]+)[,\s]*invaild_keys=(?P<invalid_keys>[\d]+)`),
-					Parser: &normalParser{},
+				&versionMatchParser{
+					verC: mustNewVersionConstraint(`~3.1.0`),
+					Parser: &regexParser{
+						name: "keyspace_info_all_~3.1.0",
+						reg: regexp.MustCompile(`(?P<db>db[\d]+)_\s*(?P<type>[^:]+):\s*keys=(?P<keys>[\d]+)[,\s]*` +
+							`expires=(?P<expire_keys>[\d]+)[,\s]*invaild_keys=(?P<invalid_keys>[\d]+)`),
+						Parser: &normalParser{},
+					},
 				},
-			},
-			&versionMatchParser{
-				verC: mustNewVersionConstraint(`3.2.0 - 3.3.2`),
-				Parser: &regexParser{
-					name: "keyspace_info_all_3.1.0-3.3.2",
-					reg: regexp.MustCompile(`(?P<db>db[\d]+)\s*(?P<type>[^_]+)\w*keys=(?P<keys>[\d]+)[,\s]*` +
-						`expires=(?P<expire_keys>[\d]+)[,\s]*invaild_keys=(?P<invalid_keys>[\d]+)`),
-					Parser: &normalParser{},
+				&versionMatchParser{
+					verC: mustNewVersionConstraint(`3.2.0 - 3.3.2`),
+					Parser: &regexParser{
+						name: "keyspace_info_all_3.1.0-3.3.2",
+						reg: regexp.MustCompile(`(?P<db>db[\d]+)\s*(?P<type>[^_]+)\w*keys=(?P<keys>[\d]+)[,\s]*` +
+							`expires=(?P<expire_keys>[\d]+)[,\s]*invaild_keys=(?P<invalid_keys>[\d]+)`),
+						Parser: &normalParser{},
+					},
 				},
-			},
-			&versionMatchParser{
-				verC: mustNewVersionConstraint(`>=3.3.3`),
-				Parser: &regexParser{
-					name: "keyspace_info_all_>=3.3.3",
-					reg: regexp.MustCompile(`(?P<db>db[\d]+)\s*(?P<type>[^_]+)\w*keys=(?P<keys>[\d]+)[,\s]*` +
-						`expires=(?P<expire_keys>[\d]+)[,\s]*invalid_keys=(?P<invalid_keys>[\d]+)`),
-					Parser: &normalParser{},
+				&versionMatchParser{
+					verC: mustNewVersionConstraint(`>=3.3.3`),
+					Parser: &regexParser{
+						name: "keyspace_info_all_>=3.3.3",
+						reg: regexp.MustCompile(`(?P<db>db[\d]+)\s*(?P<type>[^_]+)\w*keys=(?P<keys>[\d]+)[,\s]*` +
+							`expires=(?P<expire_keys>[\d]+)[,\s]*invalid_keys=(?P<invalid_keys>[\d]+)`),
+						Parser: &normalParser{},
+					},
 				},
 			},
 		},
@@ -121,4 +127,19 @@ var collectKeySpaceMetrics = map[string]MetricConfig{
 			},
 		},
 	},
+
+	"keyspace_last_scan_time": {
+		Parser: &sectionParser{
+			section: "Keyspace",
+			Parser:  &normalParser{},
+		},
+		MetricMeta: &MetaData{
+			Name:      "keyspace_last_scan_time",
+			Help:      "pika serve instance the start time of the last keyspace scan in unix seconds",
+			Type:      metricTypeGauge,
+			Labels:    []string{LabelNameAddr, LabelNameAlias},
+			ValueName: "# Time",
+			Transform: &datetimeTransform{},
+		},
+	},
 }
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Matcher interface {
+	Match(v string) bool
+}
+
+type equalMatcher struct {
+	v string
+}
+
+func (m *equalMatcher) Match(v string) bool {
+	return strings.ToLower(v) == strings.ToLower(m.v)
+}
+
+type intMatcher struct {
+	condition string
+	v         int
+}
+
+func (m *intMatcher) Match(v string) bool {
+	nv, err := strconv.Atoi(v)
+	if err != nil {
+		return false
+	}
+
+	switch m.condition {
+	case ">":
+		return nv > m.v
+	case "<":
+		return nv < m.v
+	case ">=":
+		return nv >= m.v
+	case "<=":
+		return nv <= m.v
+	}
+	return false
+}
+
+type floatMatcher struct {
+	condition string
+	v         float64
+}
+
+func (m *floatMatcher) Match(v string) bool {
+	nv, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return false
+	}
+
+	switch m.condition {
+	case ">":
+		return nv > m.v
+	case "<":
+		return nv < m.v
+	case ">=":
+		return nv >= m.v
+	case "<=":
+		return nv <= m.v
+	}
+	return false
+}
+
+type notEqualMatcher struct {
+	v string
+}
+
+func (m *notEqualMatcher) Match(v string) bool {
+	return !strings.EqualFold(v, m.v)
+}
+
+// inMatcher matches the value in the set case-insensitively, such as double_master_mode in true and 1.
+type inMatcher struct {
+	values []string
+}
+
+func (m *inMatcher) Match(v string) bool {
+	for _, value := range m.values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+type regexMatcher struct {
+	reg *regexp.Regexp
+}
+
+func (m *regexMatcher) Match(v string) bool {
+	return m.reg.MatchString(v)
+}
+
+type notMatcher struct {
+	Matcher
+}
+
+func (m *notMatcher) Match(v string) bool {
+	return !m.Matcher.Match(v)
+}
+
+// Condition is checked against all the keys of the option, for the conditions across the keys, such as
+// `role is master or connected_slaves > 0`.
+type Condition interface {
+	Check(opt ParseOption) bool
+}
+
+// keyCondition matches the value of the key, the missing key is matched as the empty value.
+type keyCondition struct {
+	key string
+	Matcher
+}
+
+func (c *keyCondition) Check(opt ParseOption) bool {
+	v, _ := opt.Lookup(c.key)
+	return c.Match(v)
+}
+
+type existsCondition struct {
+	key string
+}
+
+func (c *existsCondition) Check(opt ParseOption) bool {
+	_, ok := opt.Lookup(c.key)
+	return ok
+}
+
+// sectionCondition checks if the section is in INFO, such as the section which is only in some versions.
+type sectionCondition struct {
+	section string
+}
+
+func (c *sectionCondition) Check(opt ParseOption) bool {
+	return len(opt.Sections.Get(c.section)) > 0
+}
+
+type andCondition []Condition
+
+func (cs andCondition) Check(opt ParseOption) bool {
+	for _, c := range cs {
+		if !c.Check(opt) {
+			return false
+		}
+	}
+	return true
+}
+
+type orCondition []Condition
+
+func (cs orCondition) Check(opt ParseOption) bool {
+	for _, c := range cs {
+		if c.Check(opt) {
+			return true
+		}
+	}
+	return false
+}
+
+type notCondition struct {
+	Condition
+}
+
+func (c *notCondition) Check(opt ParseOption) bool {
+	return !c.Condition.Check(opt)
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMatcher(t *testing.T) {
+	cases := []struct {
+		matcher string
+		v       string
+		want    bool
+	}{
+		{"master", "MASTER", true},
+		{"master", "slave", false},
+		{"!=up", "down", true},
+		{"!=up", "UP", false},
+		{">0", "2", true},
+		{">0", "0", false},
+		{">0", "1.5", false},
+		{"<=0.5", "0.5", true},
+		{"<=0.5", "0.75", false},
+		{">=1e3", "1000", true},
+		{"=~db[0-9]+", "db12", true},
+		{"=~db[0-9]+", "xdb12", false},
+		{"!~write2file.*", "none", true},
+		{"!~write2file.*", "write2file12", false},
+	}
+
+	for _, c := range cases {
+		m, err := parseMatcher(c.matcher)
+		if assert.NoError(t, err, c.matcher) {
+			assert.Equal(t, c.want, m.Match(c.v), "%s match %s", c.matcher, c.v)
+		}
+	}
+
+	_, err := parseMatcher("=~(db")
+	assert.Error(t, err)
+	_, err = parseMatcher(">1.2.3")
+	assert.Error(t, err)
+}
+
+func TestCondition(t *testing.T) {
+	assert := assert.New(t)
+
+	extracts := map[string]string{"role": "slave", "master_link_status": "down", "double_master_mode": "1"}
+	opt := ParseOption{Extracts: extracts, Sections: Sections{{Name: "Replication", Arg: "SLAVE"}}}
+
+	// role is slave and master_link_status != up
+	c := andCondition{
+		&keyCondition{key: "role", Matcher: &equalMatcher{v: "slave"}},
+		&keyCondition{key: "master_link_status", Matcher: &notEqualMatcher{v: "up"}},
+	}
+	assert.True(c.Check(opt))
+
+	assert.True((&keyCondition{key: "double_master_mode", Matcher: &inMatcher{values: []string{"true", "1"}}}).Check(opt))
+	assert.True((&existsCondition{key: "role"}).Check(opt))
+	assert.False((&existsCondition{key: "connected_slaves"}).Check(opt))
+	assert.True((&notCondition{Condition: &existsCondition{key: "connected_slaves"}}).Check(opt))
+	assert.True((&sectionCondition{section: "replication"}).Check(opt))
+	assert.False((&sectionCondition{section: "Commandstats"}).Check(opt))
+
+	or := orCondition{
+		&keyCondition{key: "role", Matcher: &equalMatcher{v: "master"}},
+		&keyCondition{key: "connected_slaves", Matcher: &intMatcher{condition: ">", v: 0}},
+	}
+	assert.False(or.Check(opt))
+	extracts["connected_slaves"] = "1"
+	assert.True(or.Check(opt))
+}
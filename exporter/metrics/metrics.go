@@ -8,6 +8,7 @@ import (
 const (
 	metricTypeCounter = "counter"
 	metricTypeGauge   = "gauge"
+	metricTypeUntyped = "untyped"
 )
 
 const (
@@ -48,6 +49,18 @@ type MetaData struct {
 	Type      string
 	Labels    []string
 	ValueName string
+	// Transform converts the value of ValueName if set, otherwise the value is a number, or yes/no,
+	// up/down, online/offline and null.
+	Transform ValueTransform
+
+	// id is assigned when the metadata is registered, see ID.
+	id int
+}
+
+// ID returns the unique id of the registered metadata, so that the things built from the metadata once,
+// such as the descriptors, can be cached by it. The id of the metadata not registered is 0.
+func (m MetaData) ID() int {
+	return m.id
 }
 
 func (m MetaData) Desc(d Describer) {
@@ -58,6 +71,13 @@ func (m MetaData) Lookup(f func(m MetaData)) {
 	f(m)
 }
 
+func (m MetaData) parseValue(v string) (float64, error) {
+	if m.Transform != nil {
+		return m.Transform.Transform(v)
+	}
+	return convertToFloat64(v), nil
+}
+
 func (m MetaData) MetricsType() prometheus.ValueType {
 	switch m.Type {
 	case "counter":
@@ -96,11 +116,44 @@ type MetricConfig struct {
 
 var MetricConfigs = make(map[string]MetricConfig)
 
+var lastMetaDataID int
+
+// withIDs assigns the ids to the metadata of the metric config, the metadata which is not a pointer
+// or slice is replaced by the pointer to it.
+func withIDs(mc MetricConfig) MetricConfig {
+	switch meta := mc.MetricMeta.(type) {
+	case *MetaData:
+		lastMetaDataID++
+		meta.id = lastMetaDataID
+	case MetaData:
+		lastMetaDataID++
+		meta.id = lastMetaDataID
+		mc.MetricMeta = &meta
+	case MetaDatas:
+		for i := range meta {
+			lastMetaDataID++
+			meta[i].id = lastMetaDataID
+		}
+	case *MetaDatas:
+		for i := range *meta {
+			lastMetaDataID++
+			(*meta)[i].id = lastMetaDataID
+		}
+	}
+	return mc
+}
+
+// Register registers the metric configs and builds their lookup plans, it panics if the config names exist, or the metrics conflict
+// with the registered ones, see CheckConflicts.
 func Register(mcs map[string]MetricConfig) {
 	for k, mc := range mcs {
 		if _, ok := MetricConfigs[k]; ok {
 			panic(fmt.Sprintf("register metrics config error. metricConfigName:%s existed", k))
 		}
-		MetricConfigs[k] = mc
+		MetricConfigs[k] = withIDs(mc)
+		planLookups(MetricConfigs[k])
+	}
+	if err := CheckConflicts(MetricConfigs); err != nil {
+		panic(fmt.Sprintf("register metrics config error. %s", err.Error()))
 	}
 }
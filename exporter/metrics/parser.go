@@ -5,8 +5,8 @@ import (
 	"strconv"
 	"strings"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/Masterminds/semver"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -17,6 +17,32 @@ type ParseOption struct {
 	Version  *semver.Version
 	Extracts map[string]string
 	Info     string
+	// Sections are the sections of Info, see sectionParser.
+	Sections Sections
+
+	// layers are the keys of the sections and the regex matches layered on Extracts by the parsers,
+	// instead of copying Extracts for each of them. The latter layer takes precedence.
+	layers []map[string]string
+}
+
+// Lookup returns the value of the key in the layers of the parsers and Extracts.
+func (opt ParseOption) Lookup(key string) (string, bool) {
+	for i := len(opt.layers) - 1; i >= 0; i-- {
+		if v, ok := opt.layers[i][key]; ok {
+			return v, true
+		}
+	}
+	v, ok := opt.Extracts[key]
+	return v, ok
+}
+
+// withLayer returns the option with the layer on the top, the layers of opt are not modified.
+func (opt ParseOption) withLayer(layer map[string]string) ParseOption {
+	layers := make([]map[string]string, len(opt.layers)+1)
+	copy(layers, opt.layers)
+	layers[len(opt.layers)] = layer
+	opt.layers = layers
+	return opt
 }
 
 type Parser interface {
@@ -43,53 +69,44 @@ func (p *versionMatchParser) Parse(m MetricMeta, c Collector, opt ParseOption) {
 	p.Parser.Parse(m, c, opt)
 }
 
-type Matcher interface {
-	Match(v string) bool
-}
-
-type equalMatcher struct {
-	v string
-}
-
-func (m *equalMatcher) Match(v string) bool {
-	return strings.ToLower(v) == strings.ToLower(m.v)
-}
-
-type intMatcher struct {
-	condition string
-	v         int
+// sectionParser narrows the option to the section with the name, the Info is the text of the section,
+// and the keys of the section take precedence over the Extracts, so that the keys in other sections with the same
+// names are not mixed up. The section appearing more than once is merged.
+type sectionParser struct {
+	section string
+	Parser
 }
 
-func (m *intMatcher) Match(v string) bool {
-	nv, err := strconv.Atoi(v)
-	if err != nil {
-		return false
+func (p *sectionParser) Parse(m MetricMeta, c Collector, opt ParseOption) {
+	sections := opt.Sections.Get(p.section)
+	if len(sections) == 0 {
+		return
 	}
+	section := sections.Merge()
 
-	switch m.condition {
-	case ">":
-		return nv > m.v
-	case "<":
-		return nv < m.v
-	case ">=":
-		return nv >= m.v
-	case "<=":
-		return nv <= m.v
-	}
-	return false
+	opt.Info = section.Text()
+	opt.Sections = Sections{section}
+	opt = opt.withLayer(section.Keys)
+	p.Parser.Parse(m, c, opt)
 }
 
+// keyMatchParser applies the parser if the values of all the keys match the matchers, and the condition
+// matches if set, for the conditions which are not only on each key such as or and exists.
 type keyMatchParser struct {
-	matchers map[string]Matcher
+	matchers  map[string]Matcher
+	condition Condition
 	Parser
 }
 
 func (p *keyMatchParser) Parse(m MetricMeta, c Collector, opt ParseOption) {
 	for key, matcher := range p.matchers {
-		if v, _ := opt.Extracts[key]; !matcher.Match(v) {
+		if v, _ := opt.Lookup(key); !matcher.Match(v) {
 			return
 		}
 	}
+	if p.condition != nil && !p.condition.Check(opt) {
+		return
+	}
 	p.Parser.Parse(m, c, opt)
 }
 
@@ -97,30 +114,31 @@ type regexParser struct {
 	name   string
 	source string
 	reg    *regexp.Regexp
+	// optional is for the text which is in INFO only in some cases, so that no match is not logged.
+	optional bool
 	Parser
 }
 
 func (p *regexParser) Parse(m MetricMeta, c Collector, opt ParseOption) {
 	s := opt.Info
 	if p.source != "" {
-		s = opt.Extracts[p.source]
+		s, _ = opt.Lookup(p.source)
 	}
 
 	matchMaps := p.regMatchesToMap(s)
-	if len(matchMaps) == 0 {
+	if len(matchMaps) == 0 && !p.optional {
 		log.Warnf("regexParser::Parse reg find sub match nil. name:%s text:%s", p.name, s)
 	}
 
-	extracts := make(map[string]string)
-	for k, v := range opt.Extracts {
-		extracts[k] = v
+	if len(matchMaps) == 0 {
+		return
 	}
 
-	opt.Extracts = extracts
+	// the layer of each match replaces the former one, as the child parsers don't keep opt
+	opt = opt.withLayer(nil)
+	top := len(opt.layers) - 1
 	for _, matches := range matchMaps {
-		for k, v := range matches {
-			extracts[k] = v
-		}
+		opt.layers[top] = matches
 		p.Parser.Parse(m, c, opt)
 	}
 }
@@ -132,7 +150,9 @@ func (p *regexParser) regMatchesToMap(s string) []map[string]string {
 
 	multiMatches := p.reg.FindAllStringSubmatch(s, -1)
 	if len(multiMatches) == 0 {
-		log.Errorf("regexParser::regMatchesToMap reg find sub match nil. name:%s text:%s", p.name, s)
+		if !p.optional {
+			log.Errorf("regexParser::regMatchesToMap reg find sub match nil. name:%s text:%s", p.name, s)
+		}
 		return nil
 	}
 
@@ -146,10 +166,14 @@ func (p *regexParser) regMatchesToMap(s string) []map[string]string {
 	return ms
 }
 
-type normalParser struct{}
+type normalParser struct {
+	// plans are the lookup plans of the metadata by id, built at registration, see planLookups.
+	plans map[int]*lookupPlan
+}
 
 func (p *normalParser) Parse(m MetricMeta, c Collector, opt ParseOption) {
 	m.Lookup(func(m MetaData) {
+		plan := p.plans[m.ID()]
 		metric := Metric{
 			MetaData:    m,
 			LabelValues: make([]string, len(m.Labels)),
@@ -157,7 +181,7 @@ func (p *normalParser) Parse(m MetricMeta, c Collector, opt ParseOption) {
 		}
 
 		for i, labelName := range m.Labels {
-			labelValue, ok := findInMap(labelName, opt.Extracts)
+			labelValue, ok := plan.lookupLabel(opt, i, labelName)
 			if !ok {
 				log.Debugf("normalParser::Parse not found label value. metricName:%s labelName:%s",
 					m.Name, labelName)
@@ -167,11 +191,15 @@ func (p *normalParser) Parse(m MetricMeta, c Collector, opt ParseOption) {
 		}
 
 		if m.ValueName != "" {
-			if v, ok := findInMap(m.ValueName, opt.Extracts); !ok {
+			if v, ok := plan.lookupValue(opt, m.ValueName); !ok {
 				log.Warnf("normalParser::Parse not found value. metricName:%s valueName:%s", m.Name, m.ValueName)
 				return
+			} else if value, err := m.parseValue(v); err != nil {
+				log.Debugf("normalParser::Parse transform value failed. metricName:%s valueName:%s err:%s",
+					m.Name, m.ValueName, err.Error())
+				return
 			} else {
-				metric.Value = convertToFloat64(v)
+				metric.Value = value
 			}
 		}
 
@@ -182,15 +210,6 @@ func (p *normalParser) Parse(m MetricMeta, c Collector, opt ParseOption) {
 	})
 }
 
-func findInMap(key string, ms ...map[string]string) (string, bool) {
-	for _, m := range ms {
-		if v, ok := m[key]; ok {
-			return v, true
-		}
-	}
-	return "", false
-}
-
 func trimSpace(s string) string {
 	return strings.TrimRight(strings.TrimLeft(s, " "), " ")
 }
@@ -0,0 +1,145 @@
+package metrics
+
+// lookupLayer is the layer a parser puts on the ParseOption, as far as it's known at registration. The keys
+// are in the layer if they are anywhere in the option, and the other keys are never in the layer if closed.
+type lookupLayer struct {
+	keys   []string
+	closed bool
+}
+
+// lookupPlan is where the label values and the value of a metadata are looked up, resolved at registration
+// by the layers of the parsers above the normalParser. Each is the index of the layer from the top, or -1 to
+// look up all the layers and Extracts at parse time.
+type lookupPlan struct {
+	layers int
+	labels []int
+	value  int
+}
+
+func newLookupPlan(m MetaData, stack []lookupLayer) *lookupPlan {
+	plan := &lookupPlan{
+		layers: len(stack),
+		labels: make([]int, len(m.Labels)),
+		value:  resolveLayer(stack, m.ValueName),
+	}
+	for i, labelName := range m.Labels {
+		plan.labels[i] = resolveLayer(stack, labelName)
+	}
+	return plan
+}
+
+// resolveLayer returns the index from the top of the layer which the key is always looked up in, -1 if
+// it depends on the keys of the layers at parse time.
+func resolveLayer(stack []lookupLayer, key string) int {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if containsString(stack[i].keys, key) {
+			return len(stack) - 1 - i
+		}
+		if !stack[i].closed {
+			return -1
+		}
+	}
+	return -1
+}
+
+func (p *lookupPlan) equal(o *lookupPlan) bool {
+	if p.layers != o.layers || p.value != o.value || len(p.labels) != len(o.labels) {
+		return false
+	}
+	for i := range p.labels {
+		if p.labels[i] != o.labels[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup returns the value of the key in the layer of the plan, or in all the layers and Extracts if the
+// layer is not resolved. The plan is nil for the metadata not registered.
+func (p *lookupPlan) lookup(opt ParseOption, layer int, key string) (string, bool) {
+	if p == nil || layer < 0 || p.layers != len(opt.layers) {
+		return opt.Lookup(key)
+	}
+	v, ok := opt.layers[len(opt.layers)-1-layer][key]
+	return v, ok
+}
+
+func (p *lookupPlan) lookupLabel(opt ParseOption, i int, key string) (string, bool) {
+	if p == nil {
+		return opt.Lookup(key)
+	}
+	return p.lookup(opt, p.labels[i], key)
+}
+
+func (p *lookupPlan) lookupValue(opt ParseOption, key string) (string, bool) {
+	if p == nil {
+		return opt.Lookup(key)
+	}
+	return p.lookup(opt, p.value, key)
+}
+
+// planLookups builds the lookup plans of the metadata of the metric config, by the layers of the parsers
+// on the way to each normalParser. The parsers not known don't have the plans built under them.
+func planLookups(mc MetricConfig) {
+	planParser(mc.Parser, mc.MetricMeta, nil)
+}
+
+func planParser(p Parser, meta MetricMeta, stack []lookupLayer) {
+	switch p := p.(type) {
+	case Parsers:
+		for _, child := range p {
+			planParser(child, meta, stack)
+		}
+	case *versionMatchParser:
+		planParser(p.Parser, meta, stack)
+	case *keyMatchParser:
+		planParser(p.Parser, meta, stack)
+	case *sectionParser:
+		planParser(p.Parser, meta, pushLayer(stack, lookupLayer{}))
+	case *regexParser:
+		planParser(p.Parser, meta, pushLayer(stack, lookupLayer{keys: p.reg.SubexpNames(), closed: true}))
+	case *lowerCommandParser:
+		planParser(p.Parser, meta, pushLayer(stack, lookupLayer{keys: []string{"command"}, closed: true}))
+	case *cacheMissesParser:
+		planParser(p.Parser, meta, pushLayer(stack, lookupLayer{}))
+	case *rocksdbParser:
+		planParser(p.Parser, meta, pushLayer(stack, lookupLayer{keys: []string{"db", "type"}}))
+	case *normalParser:
+		p.plan(meta, stack)
+	}
+}
+
+func pushLayer(stack []lookupLayer, layer lookupLayer) []lookupLayer {
+	layers := make([]lookupLayer, len(stack)+1)
+	copy(layers, stack)
+	layers[len(stack)] = layer
+	return layers
+}
+
+// plan builds the lookup plans of the metadata, the normalParser reached by different layers doesn't use
+// the plans of the metadata.
+func (p *normalParser) plan(meta MetricMeta, stack []lookupLayer) {
+	meta.Lookup(func(m MetaData) {
+		if m.ID() == 0 {
+			return
+		}
+		if p.plans == nil {
+			p.plans = make(map[int]*lookupPlan)
+		}
+
+		plan := newLookupPlan(m, stack)
+		if old, ok := p.plans[m.ID()]; ok && !old.equal(plan) {
+			plan = newLookupPlan(m, nil)
+		}
+		p.plans[m.ID()] = plan
+	})
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/pourer/pika_exporter/exporter/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupPlan(t *testing.T) {
+	assert := assert.New(t)
+
+	mc := MetricConfigs["commandstats"]
+	parsers := normalParsers(mc.Parser)
+	if !assert.Len(parsers, 1) {
+		return
+	}
+
+	var calls MetaData
+	mc.MetricMeta.Lookup(func(m MetaData) {
+		if m.Name == commandCallsMetaData.Name {
+			calls = m
+		}
+	})
+	plan := parsers[0].plans[calls.ID()]
+	if assert.NotNil(plan) {
+		// addr and alias are in Extracts under the open section layer, command is in the layer of
+		// lowerCommandParser, and calls in the layer of regexParser below it.
+		assert.Equal(3, plan.layers)
+		assert.Equal([]int{-1, -1, 0}, plan.labels)
+		assert.Equal(1, plan.value)
+	}
+}
+
+func TestLookupPlan_SameMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, c := range test.InfoCases {
+		sections, err := ParseInfo(c.Info)
+		assert.NoError(err, c.Name)
+		extracts := sections.Keys()
+		extracts[LabelNameAddr] = "127.0.0.1:9221"
+		extracts[LabelNameAlias] = ""
+		version := strings.Join(strings.SplitN(extracts["pika_version"], ".", 4)[:3], ".")
+		opt := ParseOption{
+			Version:  semver.MustParse(version),
+			Extracts: extracts,
+			Info:     c.Info,
+			Sections: sections,
+		}
+
+		planned := parseAll(opt)
+		var parsers []*normalParser
+		for _, mc := range MetricConfigs {
+			parsers = append(parsers, normalParsers(mc.Parser)...)
+		}
+		plans := make([]map[int]*lookupPlan, len(parsers))
+		for i, p := range parsers {
+			plans[i], p.plans = p.plans, nil
+		}
+		unplanned := parseAll(opt)
+		for i, p := range parsers {
+			p.plans = plans[i]
+		}
+
+		assert.NotEmpty(planned, c.Name)
+		assert.Equal(unplanned, planned, c.Name)
+	}
+}
+
+func parseAll(opt ParseOption) map[string]float64 {
+	got := make(map[string]float64)
+	collector := CollectFunc(func(m Metric) error {
+		got[fmt.Sprintf("%s%v", m.Name, m.LabelValues)] = m.Value
+		return nil
+	})
+	for _, mc := range MetricConfigs {
+		mc.Parse(mc.MetricMeta, collector, opt)
+	}
+	return got
+}
+
+func normalParsers(p Parser) []*normalParser {
+	switch p := p.(type) {
+	case Parsers:
+		var parsers []*normalParser
+		for _, child := range p {
+			parsers = append(parsers, normalParsers(child)...)
+		}
+		return parsers
+	case *versionMatchParser:
+		return normalParsers(p.Parser)
+	case *keyMatchParser:
+		return normalParsers(p.Parser)
+	case *sectionParser:
+		return normalParsers(p.Parser)
+	case *regexParser:
+		return normalParsers(p.Parser)
+	case *lowerCommandParser:
+		return normalParsers(p.Parser)
+	case *cacheMissesParser:
+		return normalParsers(p.Parser)
+	case *rocksdbParser:
+		return normalParsers(p.Parser)
+	case *normalParser:
+		return []*normalParser{p}
+	}
+	return nil
+}
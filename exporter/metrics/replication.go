@@ -2,6 +2,34 @@ package metrics
 
 import "regexp"
 
+var replStateNotConnected = float64(0)
+
+// the role of the instance which is both a master and a slave is master&&slave since 3.4.
+var (
+	masterRoleMatcher = &inMatcher{values: []string{"master", "master&&slave"}}
+	slaveRoleMatcher  = &inMatcher{values: []string{"slave", "master&&slave"}}
+)
+
+// replStates are the connected states of the sync connection, 3 is the connected state of pika 2.x, the
+// others are not connected.
+var replStates = map[string]float64{
+	"connected":         1,
+	"establish success": 1,
+	"3":                 1,
+}
+
+// dbReplStates are the sync states of the dbs which are not connected to the master, since 3.2.
+var dbReplStates = map[string]float64{
+	"kNoConnect":   0,
+	"kTryConnect":  1,
+	"kTryDBSync":   2,
+	"kWaitDBSync":  3,
+	"kWaitReply":   4,
+	"kConnected":   5,
+	"kError":       6,
+	"kDBNoConnect": 7,
+}
+
 func init() {
 	Register(collectReplicationMetrics)
 }
@@ -10,7 +38,7 @@ var collectReplicationMetrics = map[string]MetricConfig{
 	"master_connected_slaves": {
 		Parser: &keyMatchParser{
 			matchers: map[string]Matcher{
-				"role": &equalMatcher{v: "master"},
+				"role": masterRoleMatcher,
 			},
 			Parser: Parsers{
 				&normalParser{},
@@ -28,7 +56,7 @@ var collectReplicationMetrics = map[string]MetricConfig{
 	"master_slave_info": {
 		Parser: &keyMatchParser{
 			matchers: map[string]Matcher{
-				"role":             &equalMatcher{v: "master"},
+				"role":             masterRoleMatcher,
 				"connected_slaves": &intMatcher{condition: ">", v: 0},
 			},
 			Parser: Parsers{
@@ -66,7 +94,7 @@ var collectReplicationMetrics = map[string]MetricConfig{
 	"master_slave_info_slave_lag": {
 		Parser: &keyMatchParser{
 			matchers: map[string]Matcher{
-				"role":             &equalMatcher{v: "master"},
+				"role":             masterRoleMatcher,
 				"connected_slaves": &intMatcher{condition: ">", v: 0},
 			},
 			Parser: Parsers{
@@ -109,7 +137,7 @@ var collectReplicationMetrics = map[string]MetricConfig{
 	"slave_info": {
 		Parser: &keyMatchParser{
 			matchers: map[string]Matcher{
-				"role": &equalMatcher{v: "slave"},
+				"role": slaveRoleMatcher,
 			},
 			Parser: Parsers{
 				&normalParser{},
@@ -130,7 +158,7 @@ var collectReplicationMetrics = map[string]MetricConfig{
 	"slave_info<3.2.0": {
 		Parser: &keyMatchParser{
 			matchers: map[string]Matcher{
-				"role": &equalMatcher{v: "slave"},
+				"role": slaveRoleMatcher,
 			},
 			Parser: &versionMatchParser{
 				verC:   mustNewVersionConstraint(`<3.2.0`),
@@ -140,10 +168,12 @@ var collectReplicationMetrics = map[string]MetricConfig{
 		MetricMeta: MetaDatas{
 			{
 				Name: "repl_state",
-				Help: "sync connection state between slave and master, when pika serve instance's " +
-					"role is slave",
-				Type:   metricTypeGauge,
-				Labels: []string{LabelNameAddr, LabelNameAlias, "master_host", "master_port", "repl_state"},
+				Help: "sync connection state between slave and master, 1 if connected, when pika serve " +
+					"instance's role is slave",
+				Type:      metricTypeGauge,
+				Labels:    []string{LabelNameAddr, LabelNameAlias, "master_host", "master_port", "repl_state"},
+				ValueName: "repl_state",
+				Transform: newEnumTransform(replStates, &replStateNotConnected),
 			},
 			{
 				Name:      "slave_read_only",
@@ -158,7 +188,7 @@ var collectReplicationMetrics = map[string]MetricConfig{
 	"slave_info>=3.0.0": {
 		Parser: &keyMatchParser{
 			matchers: map[string]Matcher{
-				"role": &equalMatcher{v: "slave"},
+				"role": slaveRoleMatcher,
 			},
 			Parser: &versionMatchParser{
 				verC:   mustNewVersionConstraint(`>=3.0.0`),
@@ -174,11 +204,93 @@ var collectReplicationMetrics = map[string]MetricConfig{
 		},
 	},
 
+	"slave_info>=3.2.0": {
+		Parser: &keyMatchParser{
+			matchers: map[string]Matcher{
+				"role": slaveRoleMatcher,
+			},
+			Parser: &versionMatchParser{
+				verC:   mustNewVersionConstraint(`>=3.2.0`),
+				Parser: &normalParser{},
+			},
+		},
+		MetricMeta: &MetaData{
+			Name:      "slave_read_only",
+			Help:      "is slave read only, when pika serve instance's role is slave",
+			Type:      metricTypeGauge,
+			Labels:    []string{LabelNameAddr, LabelNameAlias, "master_host", "master_port"},
+			ValueName: "slave_read_only",
+		},
+	},
+
+	"slave_db_repl_state>=3.2.0": {
+		Parser: &keyMatchParser{
+			matchers: map[string]Matcher{
+				"role": slaveRoleMatcher,
+			},
+			// db_repl_state is only in INFO when some dbs are not connected
+			condition: &existsCondition{key: "db_repl_state"},
+			Parser: &versionMatchParser{
+				verC: mustNewVersionConstraint(`>=3.2.0`),
+				Parser: &regexParser{
+					name:   "slave_db_repl_state_>=3.2.0",
+					source: "db_repl_state",
+					reg:    regexp.MustCompile(`\((?P<db>db[\d]+):(?P<db_repl_state>\w+)\)`),
+					Parser: &normalParser{},
+				},
+			},
+		},
+		MetricMeta: &MetaData{
+			Name: "db_repl_state",
+			Help: "sync state of the db which is not connected to the master, 0 kNoConnect, 1 kTryConnect, " +
+				"2 kTryDBSync, 3 kWaitDBSync, 4 kWaitReply, 5 kConnected, 6 kError, 7 kDBNoConnect, when pika " +
+				"serve instance's role is slave",
+			Type:      metricTypeGauge,
+			Labels:    []string{LabelNameAddr, LabelNameAlias, "master_host", "master_port", "db"},
+			ValueName: "db_repl_state",
+			Transform: newEnumTransform(dbReplStates, nil),
+		},
+	},
+
+	"consensus>=3.4.0": {
+		Parser: &versionMatchParser{
+			verC: mustNewVersionConstraint(`>=3.4.0`),
+			Parser: &sectionParser{
+				section: "Replication",
+				Parser: &regexParser{
+					name: "consensus_>=3.4.0",
+					reg: regexp.MustCompile(`(?P<db>db[\d]+)\s+consensus\s+last_log=b_offset:\s*` +
+						`filenum:\s*(?P<consensus_last_log_filenum>[\d]+)\s*offset:\s*(?P<consensus_last_log_offset>[\d]+),\s*` +
+						`l_offset:\s*term:\s*(?P<consensus_last_log_term>[\d]+)\s*index:\s*(?P<consensus_last_log_index>[\d]+)`),
+					// the consensus lines are only in INFO when consensus-level is set
+					optional: true,
+					Parser:   &normalParser{},
+				},
+			},
+		},
+		MetricMeta: MetaDatas{
+			{
+				Name:      "consensus_last_log_term",
+				Help:      "pika serve instance term of the last consensus log for each db, when consensus-level is set",
+				Type:      metricTypeGauge,
+				Labels:    []string{LabelNameAddr, LabelNameAlias, "db"},
+				ValueName: "consensus_last_log_term",
+			},
+			{
+				Name:      "consensus_last_log_index",
+				Help:      "pika serve instance index of the last consensus log for each db, when consensus-level is set",
+				Type:      metricTypeGauge,
+				Labels:    []string{LabelNameAddr, LabelNameAlias, "db"},
+				ValueName: "consensus_last_log_index",
+			},
+		},
+	},
+
 	"double_master_info": {
 		Parser: &keyMatchParser{
 			matchers: map[string]Matcher{
-				"role":               &equalMatcher{v: "master"},
-				"double_master_mode": &equalMatcher{v: "true"},
+				"role":               masterRoleMatcher,
+				"double_master_mode": &inMatcher{values: []string{"true", "1"}},
 			},
 			Parser: &regexParser{
 				name: "double_master_info",
@@ -202,12 +314,13 @@ var collectReplicationMetrics = map[string]MetricConfig{
 			},
 			{
 				Name: "double_master_repl_state",
-				Help: "double master sync state, when pika serve instance's role is master and " +
+				Help: "double master sync state, 1 if connected, when pika serve instance's role is master and " +
 					"double_master_mode is true",
 				Type: metricTypeGauge,
 				Labels: []string{LabelNameAddr, LabelNameAlias, "the_peer_master_server_id",
 					"the_peer_master_host", "the_peer_master_port"},
 				ValueName: "double_master_repl_state",
+				Transform: newEnumTransform(replStates, &replStateNotConnected),
 			},
 			{
 				Name: "double_master_recv_info_binlog_filenum",
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoubleMasterMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		name  string
+		role  string
+		state string
+		want  map[string]float64
+	}{
+		{
+			name:  "master",
+			role:  "master",
+			state: "connected",
+			want: map[string]float64{
+				"double_master_info":                     0,
+				"double_master_repl_state":               1,
+				"double_master_recv_info_binlog_filenum": 3,
+				"double_master_recv_info_binlog_offset":  1024,
+			},
+		},
+		{
+			name:  "master&&slave",
+			role:  "master&&slave",
+			state: "no connect",
+			want: map[string]float64{
+				"double_master_info":                     0,
+				"double_master_repl_state":               0,
+				"double_master_recv_info_binlog_filenum": 3,
+				"double_master_recv_info_binlog_offset":  1024,
+			},
+		},
+		{
+			name:  "slave",
+			role:  "slave",
+			state: "connected",
+			want:  map[string]float64{},
+		},
+	}
+	for _, c := range cases {
+		info := `# Server
+pika_version:3.5.0
+# Replication(MASTER)
+role:` + c.role + `
+double_master_mode:true
+# DoubleMaster(MASTER)
+the peer-master host:10.0.0.2
+the peer-master port:9221
+the peer-master server_id:2
+repl_state:` + c.state + `
+double_master_recv_info: filenum 3 offset 1024
+`
+		sections, err := ParseInfo(info)
+		assert.NoError(err, c.name)
+		extracts := sections.Keys()
+		extracts[LabelNameAddr] = "127.0.0.1:9221"
+		extracts[LabelNameAlias] = ""
+		opt := ParseOption{
+			Version:  semver.MustParse("3.5.0"),
+			Extracts: extracts,
+			Info:     info,
+			Sections: sections,
+		}
+
+		got := make(map[string]float64)
+		collector := CollectFunc(func(m Metric) error {
+			assert.Equal([]string{"127.0.0.1:9221", "", "2", "10.0.0.2", "9221"}, m.LabelValues, c.name)
+			got[m.Name] = m.Value
+			return nil
+		})
+		mc := MetricConfigs["double_master_info"]
+		mc.Parse(mc.MetricMeta, collector, opt)
+		assert.Equal(c.want, got, c.name)
+	}
+}
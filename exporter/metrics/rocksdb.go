@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register(collectRocksDBMetrics)
+}
+
+// rocksdbSectionReg matches the sections such as `#strings_RocksDB` following `# RocksDB`, each of which has the
+// properties of the RocksDB instance of a data type, such as `strings_num_running_compactions:0`.
+var rocksdbSectionReg = regexp.MustCompile(`^([a-z]+)_RocksDB$`)
+
+// rocksdbParser applies the parser to each data type section of each db, with the properties without the prefix
+// of the data type, and the labels db and type. The sections don't have the name of the db, but pika prints them
+// db by db in the order of the db names, and there are no more than 8 dbs, so the db is told by the order of the
+// section among the ones of the same data type.
+type rocksdbParser struct {
+	Parser
+}
+
+func (p *rocksdbParser) Parse(m MetricMeta, c Collector, opt ParseOption) {
+	dbs := make(map[string]int)
+	for _, section := range opt.Sections {
+		matches := rocksdbSectionReg.FindStringSubmatch(section.Name)
+		if matches == nil {
+			continue
+		}
+
+		dataType := matches[1]
+		layer := map[string]string{
+			"db":   "db" + strconv.Itoa(dbs[dataType]),
+			"type": dataType,
+		}
+		dbs[dataType]++
+
+		prefix := dataType + "_"
+		for k, v := range section.Keys {
+			layer[strings.TrimPrefix(k, prefix)] = v
+		}
+		p.Parser.Parse(m, c, opt.withLayer(layer))
+	}
+}
+
+var rocksdbLabels = []string{LabelNameAddr, LabelNameAlias, "db", "type"}
+
+var collectRocksDBMetrics = map[string]MetricConfig{
+	"rocksdb>=3.5.0": {
+		Parser: &versionMatchParser{
+			verC:   mustNewVersionConstraint(`>=3.5.0`),
+			Parser: &rocksdbParser{Parser: &normalParser{}},
+		},
+		MetricMeta: MetaDatas{
+			{
+				Name:      "rocksdb_num_immutable_mem_table",
+				Help:      "pika serve instance count of the immutable memtables not flushed yet of the db's type",
+				Type:      metricTypeGauge,
+				Labels:    rocksdbLabels,
+				ValueName: "num_immutable_mem_table",
+			},
+			{
+				Name:      "rocksdb_mem_table_flush_pending",
+				Help:      "pika serve instance whether a memtable flush is pending of the db's type, 1 if pending",
+				Type:      metricTypeGauge,
+				Labels:    rocksdbLabels,
+				ValueName: "mem_table_flush_pending",
+			},
+			{
+				Name:      "rocksdb_num_running_flushes",
+				Help:      "pika serve instance count of the running flushes of the db's type",
+				Type:      metricTypeGauge,
+				Labels:    rocksdbLabels,
+				ValueName: "num_running_flushes",
+			},
+			{
+				Name:      "rocksdb_compaction_pending",
+				Help:      "pika serve instance whether a compaction is pending of the db's type, 1 if pending",
+				Type:      metricTypeGauge,
+				Labels:    rocksdbLabels,
+				ValueName: "compaction_pending",
+			},
+			{
+				Name:      "rocksdb_num_running_compactions",
+				Help:      "pika serve instance count of the running compactions of the db's type",
+				Type:      metricTypeGauge,
+				Labels:    rocksdbLabels,
+				ValueName: "num_running_compactions",
+			},
+			{
+				Name:      "rocksdb_background_errors",
+				Help:      "pika serve instance accumulated count of the background errors of the db's type",
+				Type:      metricTypeCounter,
+				Labels:    rocksdbLabels,
+				ValueName: "background_errors",
+			},
+			{
+				Name:      "rocksdb_cur_size_active_mem_table",
+				Help:      "pika serve instance approximate size in bytes of the active memtable of the db's type",
+				Type:      metricTypeGauge,
+				Labels:    rocksdbLabels,
+				ValueName: "cur_size_active_mem_table",
+			},
+			{
+				Name:      "rocksdb_cur_size_all_mem_tables",
+				Help:      "pika serve instance approximate size in bytes of the active and unflushed immutable memtables of the db's type",
+				Type:      metricTypeGauge,
+				Labels:    rocksdbLabels,
+				ValueName: "cur_size_all_mem_tables",
+			},
+			{
+				Name:      "rocksdb_size_all_mem_tables",
+				Help:      "pika serve instance approximate size in bytes of the active, unflushed and pinned immutable memtables of the db's type",
+				Type:      metricTypeGauge,
+				Labels:    rocksdbLabels,
+				ValueName: "size_all_mem_tables",
+			},
+			{
+				Name:      "rocksdb_estimate_pending_compaction_bytes",
+				Help:      "pika serve instance estimated bytes to be rewritten by compactions to get all levels under target size of the db's type",
+				Type:      metricTypeGauge,
+				Labels:    rocksdbLabels,
+				ValueName: "estimate_pending_compaction_bytes",
+			},
+			{
+				Name:      "rocksdb_block_cache_capacity",
+				Help:      "pika serve instance capacity in bytes of the block cache of the db's type",
+				Type:      metricTypeGauge,
+				Labels:    rocksdbLabels,
+				ValueName: "block_cache_capacity",
+			},
+			{
+				Name:      "rocksdb_block_cache_usage",
+				Help:      "pika serve instance memory size in bytes of the entries in the block cache of the db's type",
+				Type:      metricTypeGauge,
+				Labels:    rocksdbLabels,
+				ValueName: "block_cache_usage",
+			},
+			{
+				Name:      "rocksdb_block_cache_pinned_usage",
+				Help:      "pika serve instance memory size in bytes of the pinned entries in the block cache of the db's type",
+				Type:      metricTypeGauge,
+				Labels:    rocksdbLabels,
+				ValueName: "block_cache_pinned_usage",
+			},
+			{
+				Name:      "rocksdb_num_files_at_level0",
+				Help:      "pika serve instance count of the sst files at level 0 of the db's type",
+				Type:      metricTypeGauge,
+				Labels:    rocksdbLabels,
+				ValueName: "num_files_at_level0",
+			},
+		},
+	},
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const rocksdbInfo = `# Server
+pika_version:3.5.0
+# RocksDB
+#strings_RocksDB
+strings_num_running_compactions:2
+strings_num_files_at_level0:9
+#hashes_RocksDB
+hashes_num_running_compactions:0
+hashes_num_files_at_level0:1
+#strings_RocksDB
+strings_num_running_compactions:1
+strings_num_files_at_level0:3
+#hashes_RocksDB
+hashes_num_running_compactions:0
+hashes_num_files_at_level0:0
+`
+
+func TestRocksDBParser(t *testing.T) {
+	assert := assert.New(t)
+
+	sections, err := ParseInfo(rocksdbInfo)
+	assert.NoError(err)
+	opt := ParseOption{
+		Extracts: map[string]string{LabelNameAddr: "127.0.0.1:9221", LabelNameAlias: ""},
+		Sections: sections,
+	}
+
+	got := make(map[string]float64)
+	collector := CollectFunc(func(m Metric) error {
+		got[m.Name+" "+m.LabelValues[2]+" "+m.LabelValues[3]] = m.Value
+		return nil
+	})
+	meta := MetaDatas{
+		{Name: "rocksdb_num_running_compactions", Labels: rocksdbLabels, ValueName: "num_running_compactions"},
+		{Name: "rocksdb_num_files_at_level0", Labels: rocksdbLabels, ValueName: "num_files_at_level0"},
+	}
+	(&rocksdbParser{Parser: &normalParser{}}).Parse(meta, collector, opt)
+
+	assert.Equal(map[string]float64{
+		"rocksdb_num_running_compactions db0 strings": 2,
+		"rocksdb_num_running_compactions db0 hashes":  0,
+		"rocksdb_num_running_compactions db1 strings": 1,
+		"rocksdb_num_running_compactions db1 hashes":  0,
+		"rocksdb_num_files_at_level0 db0 strings":     9,
+		"rocksdb_num_files_at_level0 db0 hashes":      1,
+		"rocksdb_num_files_at_level0 db1 strings":     3,
+		"rocksdb_num_files_at_level0 db1 hashes":      0,
+	}, got)
+}
@@ -37,6 +37,28 @@ var collectStatsMetrics = map[string]MetricConfig{
 			ValueName: "total_commands_processed",
 		},
 	},
+	"keyspace_hits>=3.5.0": {
+		Parser: &versionMatchParser{
+			verC:   mustNewVersionConstraint(`>=3.5.0`),
+			Parser: &normalParser{},
+		},
+		MetricMeta: MetaDatas{
+			{
+				Name:      "keyspace_hits",
+				Help:      "pika serve instance total count of the successful lookups of keys",
+				Type:      metricTypeCounter,
+				Labels:    []string{LabelNameAddr, LabelNameAlias},
+				ValueName: "keyspace_hits",
+			},
+			{
+				Name:      "keyspace_misses",
+				Help:      "pika serve instance total count of the failed lookups of keys",
+				Type:      metricTypeCounter,
+				Labels:    []string{LabelNameAddr, LabelNameAlias},
+				ValueName: "keyspace_misses",
+			},
+		},
+	},
 	"is_bgsaving": {
 		Parser: &regexParser{
 			name:   "is_bgsaving",
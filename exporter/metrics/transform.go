@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	transformTypeSize     = "size"
+	transformTypeEnum     = "enum"
+	transformTypeDatetime = "datetime"
+	transformTypeRegex    = "regex"
+
+	defaultDatetimeLayout = "2006-01-02 15:04:05"
+)
+
+var sizeReg = regexp.MustCompile(`^(?i)([\d.]+)\s*([KMGTPE]?)I?B?$`)
+
+// ValueTransform converts the value in INFO to the value of the metric, for the values which are not
+// plain numbers, such as `used_memory_human:966M` or `repl_state: connected`.
+type ValueTransform interface {
+	Transform(v string) (float64, error)
+}
+
+// sizeTransform converts the human readable size such as 966M or 1.5G to bytes, the units are 1024-based.
+type sizeTransform struct{}
+
+func (t *sizeTransform) Transform(v string) (float64, error) {
+	matches := sizeReg.FindStringSubmatch(strings.TrimSpace(v))
+	if matches == nil {
+		return 0, fmt.Errorf("invalid size %q", v)
+	}
+
+	n, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", v)
+	}
+
+	unit := strings.ToUpper(matches[2])
+	if unit != "" {
+		n *= float64(uint64(1) << (10 * uint(strings.Index("KMGTPE", unit)+1)))
+	}
+	return n, nil
+}
+
+// enumTransform maps the value to the number case-insensitively, the value not in the map is converted
+// to defaultValue if set, otherwise it's an error.
+type enumTransform struct {
+	values       map[string]float64
+	defaultValue *float64
+}
+
+func newEnumTransform(values map[string]float64, defaultValue *float64) *enumTransform {
+	t := &enumTransform{values: make(map[string]float64, len(values)), defaultValue: defaultValue}
+	for k, v := range values {
+		t.values[strings.ToLower(k)] = v
+	}
+	return t
+}
+
+func (t *enumTransform) Transform(v string) (float64, error) {
+	if n, ok := t.values[strings.ToLower(strings.TrimSpace(v))]; ok {
+		return n, nil
+	}
+	if t.defaultValue != nil {
+		return *t.defaultValue, nil
+	}
+	return 0, fmt.Errorf("unknown enum value %q", v)
+}
+
+// datetimeTransform converts the date time such as `# Time:2019-08-02 10:56:55` to the unix seconds.
+type datetimeTransform struct {
+	layout   string
+	location *time.Location
+}
+
+func (t *datetimeTransform) Transform(v string) (float64, error) {
+	layout, location := t.layout, t.location
+	if layout == "" {
+		layout = defaultDatetimeLayout
+	}
+	if location == nil {
+		location = time.Local
+	}
+
+	tm, err := time.ParseInLocation(layout, strings.TrimSpace(v), location)
+	if err != nil {
+		return 0, err
+	}
+	return float64(tm.Unix()), nil
+}
+
+// regexTransform converts the number captured by the regex, such as 38101 of `safety_purge:write2file38101`.
+// The group named value is captured if any, otherwise the first group, so the regex has at least one group.
+type regexTransform struct {
+	reg *regexp.Regexp
+}
+
+func (t *regexTransform) Transform(v string) (float64, error) {
+	matches := t.reg.FindStringSubmatch(v)
+	if matches == nil {
+		return 0, fmt.Errorf("regex %s not matched %q", t.reg.String(), v)
+	}
+
+	index := 1
+	for i, name := range t.reg.SubexpNames() {
+		if name == "value" {
+			index = i
+			break
+		}
+	}
+	return strconv.ParseFloat(strings.TrimSpace(matches[index]), 64)
+}
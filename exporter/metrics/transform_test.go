@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueTransform(t *testing.T) {
+	notConnected := 0.0
+	cases := []struct {
+		name      string
+		transform ValueTransform
+		v         string
+		want      float64
+		err       bool
+	}{
+		{"size bytes", &sizeTransform{}, "1024", 1024, false},
+		{"size M", &sizeTransform{}, "966M", 966 << 20, false},
+		{"size GiB", &sizeTransform{}, "1.5GiB", 1.5 * (1 << 30), false},
+		{"size lower", &sizeTransform{}, "2k", 2048, false},
+		{"size invalid", &sizeTransform{}, "abc", 0, true},
+		{"enum", newEnumTransform(map[string]float64{"Connected": 1}, nil), "connected", 1, false},
+		{"enum unknown", newEnumTransform(map[string]float64{"connected": 1}, nil), "no connect", 0, true},
+		{"enum default", newEnumTransform(map[string]float64{"connected": 1}, &notConnected), "no connect", 0, false},
+		{"datetime", &datetimeTransform{location: time.UTC}, "2019-08-02 10:56:55", 1564743415, false},
+		{"datetime layout", &datetimeTransform{layout: time.RFC3339}, "2019-08-02T10:56:55Z", 1564743415, false},
+		{"datetime invalid", &datetimeTransform{}, "yesterday", 0, true},
+		{"regex", &regexTransform{reg: regexp.MustCompile(`write2file(\d+)`)}, "write2file38101", 38101, false},
+		{"regex named", &regexTransform{reg: regexp.MustCompile(`(\w+)=(?P<value>\d+)`)}, "lag=12", 12, false},
+		{"regex not matched", &regexTransform{reg: regexp.MustCompile(`write2file(\d+)`)}, "none", 0, true},
+	}
+
+	for _, c := range cases {
+		v, err := c.transform.Transform(c.v)
+		if c.err {
+			assert.Error(t, err, c.name)
+			continue
+		}
+		if assert.NoError(t, err, c.name) {
+			assert.Equal(t, c.want, v, c.name)
+		}
+	}
+}
+
+func TestNormalParser_Transform(t *testing.T) {
+	assert := assert.New(t)
+
+	var collected []Metric
+	collector := CollectFunc(func(m Metric) error {
+		collected = append(collected, m)
+		return nil
+	})
+	opt := ParseOption{Extracts: map[string]string{"safety_purge": "none", "used_memory_human": "87M"}}
+	meta := MetaDatas{
+		{Name: "safety_purge", ValueName: "safety_purge", Transform: safetyPurgeTransform},
+		{Name: "used_memory", ValueName: "used_memory_human", Transform: &sizeTransform{}},
+	}
+	(&normalParser{}).Parse(meta, collector, opt)
+
+	// the value failed to transform is left out
+	if assert.Len(collected, 1) {
+		assert.Equal("used_memory", collected[0].Name)
+		assert.Equal(87.0*1024*1024, collected[0].Value)
+	}
+}
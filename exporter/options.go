@@ -0,0 +1,181 @@
+package exporter
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	CollectorInfo    = "info"
+	CollectorKeys    = "keys"
+	CollectorPing    = "ping"
+	CollectorSlowlog = "slowlog"
+	CollectorConfig  = "config"
+	CollectorClients = "clients"
+	// CollectorReplicationLag exports the replication lag by the binlog offsets in INFO of the info collector.
+	CollectorReplicationLag = "replication_lag"
+)
+
+// Collectors are the names of all the collectors.
+var Collectors = []string{CollectorInfo, CollectorKeys, CollectorPing, CollectorSlowlog, CollectorConfig,
+	CollectorClients, CollectorReplicationLag}
+
+// DefaultCollectors are the collectors enabled if not given. The others issue more commands to every instance,
+// and are enabled by Options.Collectors or InstanceOptions.Collectors.
+var DefaultCollectors = []string{CollectorInfo, CollectorKeys, CollectorPing}
+
+// DefaultConfigParameters are the parameters of CONFIG GET exported by the config collector by default.
+var DefaultConfigParameters = []string{"maxclients", "thread-num", "write-buffer-size", "max-cache-files",
+	"slave-read-only", "write-binlog"}
+
+const (
+	defaultTimeout           = 5 * time.Second
+	defaultScrapeConcurrency = 64
+)
+
+type Options struct {
+	Namespace      string
+	KeyPatterns    []string
+	Keys           []string
+	ScanCount      int
+	StatsClockHour int
+	Timeout        time.Duration
+	Collectors     []string
+	// ConfigParameters are the parameters of CONFIG GET exported by the config collector, nil is
+	// DefaultConfigParameters.
+	ConfigParameters []string
+	// PoolMaxIdle is the max idle connections kept for each instance, 0 is the default, < 0 disables the pool.
+	PoolMaxIdle     int
+	PoolIdleTimeout time.Duration
+	// ScrapeInterval > 0 scrapes the instances in the background every interval, and Collect serves
+	// the snapshot of the last scrape instead of scraping the instances itself.
+	ScrapeInterval time.Duration
+	// ScrapeConcurrency is the max instances scraped at the same time, 0 is the default, < 0 is unlimited.
+	ScrapeConcurrency int
+	// ClientListInterval is the min interval of CLIENT LIST of the clients collector, <= 0 is the default,
+	// the scrapes in between export the last result.
+	ClientListInterval time.Duration
+	// ClientListTopN is the number of the client IPs with the most connections exported, the others are
+	// exported as the IP other, <= 0 is the default.
+	ClientListTopN int
+	// BinlogFileSize > 0 is the binlog file size of all the instances to convert the binlog offsets to bytes,
+	// otherwise binlog-file-size of CONFIG GET of each instance, for the replication_lag collector.
+	BinlogFileSize int64
+	// Instances overrides the options above for the pika instance with the same addr.
+	Instances map[string]InstanceOptions
+}
+
+// InstanceOptions overrides the global Options, the nil fields and zero Timeout are inherited.
+type InstanceOptions struct {
+	KeyPatterns      []string
+	Keys             []string
+	Timeout          time.Duration
+	Collectors       []string
+	ConfigParameters []string
+}
+
+type instanceOptions struct {
+	keyPatterns, keys []dbKeyPair
+	timeout           time.Duration
+	collectors        map[string]bool
+	configParameters  []string
+}
+
+func newInstanceOptions(opt Options, override InstanceOptions) (*instanceOptions, error) {
+	if override.KeyPatterns != nil {
+		opt.KeyPatterns = override.KeyPatterns
+	}
+	if override.Keys != nil {
+		opt.Keys = override.Keys
+	}
+	if override.Timeout > 0 {
+		opt.Timeout = override.Timeout
+	}
+	if override.Collectors != nil {
+		opt.Collectors = override.Collectors
+	}
+	if override.ConfigParameters != nil {
+		opt.ConfigParameters = override.ConfigParameters
+	}
+
+	o := &instanceOptions{
+		timeout:          opt.Timeout,
+		collectors:       make(map[string]bool),
+		configParameters: opt.ConfigParameters,
+	}
+	if o.timeout <= 0 {
+		o.timeout = defaultTimeout
+	}
+	if o.configParameters == nil {
+		o.configParameters = DefaultConfigParameters
+	}
+
+	var err error
+	if o.keyPatterns, err = parseKeys(opt.KeyPatterns); err != nil {
+		return nil, err
+	}
+	if o.keys, err = parseKeys(opt.Keys); err != nil {
+		return nil, err
+	}
+
+	if opt.Collectors == nil {
+		opt.Collectors = DefaultCollectors
+	}
+	for _, name := range opt.Collectors {
+		if err := ValidateCollector(name); err != nil {
+			return nil, err
+		}
+		o.collectors[name] = true
+	}
+	return o, nil
+}
+
+func ValidateCollector(name string) error {
+	for _, c := range Collectors {
+		if c == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown collector: %s, valid options: %s", name, strings.Join(Collectors, " "))
+}
+
+func ValidateKey(k string) error {
+	_, err := parseKey(k)
+	return err
+}
+
+func parseKeys(ks []string) ([]dbKeyPair, error) {
+	var keys []dbKeyPair
+	for _, k := range ks {
+		key, err := parseKey(k)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func parseKey(k string) (dbKeyPair, error) {
+	var (
+		db  = "0"
+		key string
+		err error
+	)
+	frags := strings.Split(k, "=")
+	switch len(frags) {
+	case 1:
+		key, err = url.QueryUnescape(strings.TrimSpace(frags[0]))
+	case 2:
+		db = strings.Replace(strings.TrimSpace(frags[0]), "db", "", -1)
+		key, err = url.QueryUnescape(strings.TrimSpace(frags[1]))
+	default:
+		return dbKeyPair{}, fmt.Errorf("invalid key list argument: %s", k)
+	}
+	if err != nil {
+		return dbKeyPair{}, fmt.Errorf("couldn't parse db/key string: %s", k)
+	}
+	return dbKeyPair{db, key}, nil
+}
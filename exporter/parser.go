@@ -1,69 +1,37 @@
 package exporter
 
 import (
-	"bufio"
 	"errors"
 	"strings"
 
 	"github.com/Masterminds/semver"
+	"github.com/pourer/pika_exporter/exporter/metrics"
 )
 
 const (
-	keyValueSeparator = ":"
-	pikaVersionKey    = "pika_version"
+	pikaVersionKey = "pika_version"
 )
 
-func parseInfo(info string) (*semver.Version, map[string]string, error) {
-	extracts, err := extractInfo(info)
+// parseInfo parses INFO into the option of the metric parsers, the extracts are the keys of all the
+// sections, and the sections are kept for the parsers targeting a section.
+func parseInfo(info string) (metrics.ParseOption, error) {
+	sections, err := metrics.ParseInfo(info)
 	if err != nil {
-		return nil, nil, err
+		return metrics.ParseOption{}, err
 	}
 
+	extracts := sections.Keys()
 	version, err := semver.NewVersion(getVersion(extracts))
 	if err != nil {
-		return nil, nil, errors.New("invalid version in info")
+		return metrics.ParseOption{}, errors.New("invalid version in info")
 	}
 
-	return version, extracts, nil
-}
-
-func extractInfo(s string) (map[string]string, error) {
-	m := make(map[string]string)
-	scanner := bufio.NewScanner(strings.NewReader(s))
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		cleanLine := trimSpace(line)
-		if cleanLine == "" {
-			continue
-		}
-
-		k, v := fetchKV(cleanLine)
-		m[k] = v
-	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-
-	return m, scanner.Err()
-}
-
-func trimSpace(s string) string {
-	s = strings.TrimLeft(s, " ")
-	s = strings.TrimRight(s, " ")
-	return s
-}
-
-func fetchKV(s string) (k, v string) {
-	pos := strings.Index(s, keyValueSeparator)
-	if pos < 0 {
-		k = s
-		return
-	}
-
-	k = trimSpace(s[:pos])
-	v = trimSpace(s[pos+1:])
-	return
+	return metrics.ParseOption{
+		Version:  version,
+		Extracts: extracts,
+		Info:     info,
+		Sections: sections,
+	}, nil
 }
 
 func getVersion(extracted map[string]string) (version string) {
@@ -1,10 +1,19 @@
 package exporter
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
+
 	"github.com/Masterminds/semver"
-	"github.com/pourer/pika_exporter/exporter/test"
+	"github.com/pourer/pika_exporter/discovery"
 	"github.com/pourer/pika_exporter/exporter/metrics"
+	"github.com/pourer/pika_exporter/exporter/test"
+	"github.com/pourer/pika_exporter/internal/respserver"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -18,23 +27,18 @@ func mustNewVersionConstraint(version string) *semver.Constraints {
 
 func Test_Parse_Info(t *testing.T) {
 	for _, infoCase := range test.InfoCases {
-		version, extracts, err := parseInfo(infoCase.Info)
+		parseOpt, err := parseInfo(infoCase.Info)
 		if err != nil {
 			t.Errorf("%s parse info fialed. err:%s", infoCase.Name, err.Error())
 		}
 
-		extracts[metrics.LabelNameAddr] = "127.0.0.1"
-		extracts[metrics.LabelNameAlias] = ""
+		parseOpt.Extracts[metrics.LabelNameAddr] = "127.0.0.1"
+		parseOpt.Extracts[metrics.LabelNameAlias] = ""
 
 		collector := metrics.CollectFunc(func(m metrics.Metric) error {
 			t.Logf("metric:%#v", m)
 			return nil
 		})
-		parseOpt := metrics.ParseOption{
-			Version:  version,
-			Extracts: extracts,
-			Info:     infoCase.Info,
-		}
 		t.Logf("##########%s begin parse###########", infoCase.Name)
 		for _, m := range metrics.MetricConfigs {
 			m.Parse(m, collector, parseOpt)
@@ -42,6 +46,130 @@ func Test_Parse_Info(t *testing.T) {
 	}
 }
 
+// gatherInfo scrapes a pika instance replying the INFO by the exporter, and gathers the metrics by
+// a pedantic registry. The collected metrics must be described by the same descriptors.
+func gatherInfo(t *testing.T, info string) (map[string]bool, error) {
+	s := respserver.New(t, func(args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "INFO":
+			return info
+		case "CONFIG":
+			return []string{binlogFileSizeParameter, "104857600"}
+		}
+		return respserver.Status("OK")
+	})
+	defer s.Close()
+
+	dis := discovery.NewStaticDiscovery(discovery.Instance{Addr: s.Addr(), Labels: map[string]string{"cluster": "c1"}})
+	e, err := newExporter(dis, Options{Namespace: "pika", Collectors: []string{CollectorInfo}})
+	if err != nil {
+		return nil, err
+	}
+	defer e.Close()
+
+	// the metrics are described with the instance labels of the last scrape.
+	var collected []prometheus.Metric
+	ch := make(chan prometheus.Metric)
+	go func() {
+		e.Collect(ch)
+		close(ch)
+	}()
+	for m := range ch {
+		collected = append(collected, m)
+	}
+	described := make(map[string]bool)
+	descCh := make(chan *prometheus.Desc)
+	go func() {
+		e.Describe(descCh)
+		close(descCh)
+	}()
+	for desc := range descCh {
+		described[desc.String()] = true
+	}
+	var undescribed []string
+	for _, m := range collected {
+		if !described[m.Desc().String()] {
+			undescribed = append(undescribed, m.Desc().String())
+		}
+	}
+	if len(undescribed) > 0 {
+		return nil, fmt.Errorf("collected metrics not described: %v", undescribed)
+	}
+
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(e); err != nil {
+		return nil, err
+	}
+	mfs, err := registry.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, mf := range mfs {
+		names[mf.GetName()] = true
+	}
+	return names, nil
+}
+
+// Test_InfoMetrics_Fixtures checks every registered metric config against every fixture, the metrics
+// scraped by the exporter must be gathered by Prometheus without the duplicate series, the inconsistent
+// declarations or the descriptors not described.
+func Test_InfoMetrics_Fixtures(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, infoCase := range test.InfoCases {
+		names, err := gatherInfo(t, infoCase.Info)
+		if !assert.NoError(err, infoCase.Name) {
+			continue
+		}
+		assert.True(names["pika_uptime_in_seconds"], infoCase.Name)
+		assert.False(names["pika_exporter_last_scrape_error"], infoCase.Name)
+	}
+}
+
+// Test_InfoMetrics_Versions checks the metrics which must be parsed from the INFO of each release.
+func Test_InfoMetrics_Versions(t *testing.T) {
+	assert := assert.New(t)
+
+	infos := make(map[string]string)
+	for _, infoCase := range test.InfoCases {
+		infos[infoCase.Name] = infoCase.Info
+	}
+
+	master := []string{"build_info", "db_size", "used_memory", "connected_clients", "total_commands_processed",
+		"connected_slaves", "slave_lag", "binlog_offset_db", "binlog_offset_filenum_db", "keys", "expire_keys",
+		"invalid_keys", "keyspace_last_scan_time", "command_exec_count", "command_calls"}
+	slave := []string{"build_info", "db_size", "used_memory", "master_link_status", "slave_priority",
+		"slave_read_only", "binlog_offset_db", "keys"}
+	cases := []struct {
+		name    string
+		metrics []string
+	}{
+		{"v3.3.5_master", master},
+		{"v3.3.5_slave", slave},
+		{"v3.4.2_master", append([]string{"binlog_safety_purge_filenum_db", "consensus_last_log_term",
+			"consensus_last_log_index"}, master...)},
+		{"v3.4.2_slave", append([]string{"db_repl_state"}, slave...)},
+		{"v3.5.0_master", append([]string{"keyspace_hits", "keyspace_misses", "rocksdb_num_running_compactions",
+			"rocksdb_estimate_pending_compaction_bytes", "rocksdb_num_files_at_level0", "db_memtable_usage",
+			"db_tablereader_usage", "db_fatal"}, master...)},
+		// master&&slave
+		{"v3.5.0_slave", append([]string{"connected_slaves", "slave_lag"}, slave...)},
+		{"v4.0.0_master", append([]string{"cache_status", "cache_hits", "cache_misses", "cache_hitratio_all",
+			"command_calls", "command_usec", "command_usec_per_call"}, master...)},
+	}
+	for _, c := range cases {
+		names, err := gatherInfo(t, infos[c.name])
+		if !assert.NoError(err, c.name) {
+			continue
+		}
+		for _, name := range c.metrics {
+			assert.True(names["pika_"+name], "%s: metric %s not found", c.name, name)
+		}
+	}
+}
+
 func Test_Parse_Version_Error(t *testing.T) {
 	assert := assert.New(t)
 
@@ -51,35 +179,63 @@ pika_git_sha:b22b0561f9093057d2e2d5cc783ff630fb2c8884
 pika_build_compile_date: Nov  7 2019
 os:Linux 3.10.0-1062.9.1.el7.x86_64 x86_64`
 
-	version, _, err := parseInfo(info)
-	assert.Nil(version)
+	parseOpt, err := parseInfo(info)
+	assert.Nil(parseOpt.Version)
 	assert.Error(err)
 }
 
+// discardLog discards the logs of the metrics not found in INFO, which are written on every parse.
+func discardLog(b *testing.B) {
+	log.SetOutput(ioutil.Discard)
+	b.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+	})
+}
+
 func Benchmark_Parse(b *testing.B) {
+	discardLog(b)
+	b.ReportAllocs()
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
 			info := test.V320MasterInfo
 
-			version, extracts, err := parseInfo(info)
+			parseOpt, err := parseInfo(info)
 			if err != nil {
 				b.Error(err)
 			}
 
-			extracts[metrics.LabelNameAddr] = "127.0.0.1"
-			extracts[metrics.LabelNameAlias] = ""
+			parseOpt.Extracts[metrics.LabelNameAddr] = "127.0.0.1"
+			parseOpt.Extracts[metrics.LabelNameAlias] = ""
 
 			collector := metrics.CollectFunc(func(m metrics.Metric) error {
 				return nil
 			})
-			parseOpt := metrics.ParseOption{
-				Version:  version,
-				Extracts: extracts,
-				Info:     info,
-			}
 			for _, m := range metrics.MetricConfigs {
 				m.Parse(m, collector, parseOpt)
 			}
 		}
 	})
 }
+
+func Benchmark_InfoMetrics(b *testing.B) {
+	discardLog(b)
+	e, err := newExporter(&fakeDiscovery{}, Options{Namespace: "pika"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	e.refreshInstances([]discovery.Instance{{Addr: "127.0.0.1", Labels: map[string]string{"cluster": "c1"}}})
+
+	for _, infoCase := range []struct{ name, info string }{
+		{"v3.2.0_master", test.V320MasterInfo},
+		{"v3.3.5_slave", test.V335SlaveInfo},
+	} {
+		b.Run(infoCase.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := e.infoMetrics(infoCase.info, "127.0.0.1", ""); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,13 +1,13 @@
 package exporter
 
 import (
+	"context"
 	"fmt"
 	"github.com/garyburd/redigo/redis"
-	"net/url"
 	"regexp"
 	"strconv"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pourer/pika_exporter/discovery"
@@ -21,48 +21,142 @@ type dbKeyPair struct {
 }
 
 type exporter struct {
-	dis                 discovery.Discovery
-	namespace           string
-	keyPatterns, keys   []dbKeyPair
-	scanCount           int
-	collectDuration     prometheus.Histogram
-	collectCount        prometheus.Counter
+	dis                discovery.Discovery
+	namespace          string
+	scanCount          int
+	defaultOptions     *instanceOptions
+	instanceOptions    map[string]*instanceOptions
+	collectDuration    prometheus.Histogram
+	collectCount       prometheus.Counter
+	queueWait          prometheus.Histogram
+	snapshotAge        *prometheus.Desc
+	snapshot           atomic.Value
+	scrapeInterval     time.Duration
+	scrapeConcurrency  int
+	scrapeRound        int
+	pools              *clientPools
+	instanceMetrics    atomic.Value
+	lastErrors         map[futureKey]map[string]struct{}
+	slowlogs           map[futureKey]*slowlogState
+	slowlogMutex       sync.Mutex
+	clientLists        map[futureKey]*clientListStats
+	clientListMutex    sync.Mutex
+	clientListInterval time.Duration
+	clientListTopN     int
+	binlogFileSizes    map[futureKey]binlogFileSize
+	binlogMutex        sync.Mutex
+	binlogFileSize     int64
+	mutex              *sync.Mutex
+	wg                 sync.WaitGroup
+	inflight           sync.WaitGroup
+	done               chan struct{}
+}
+
+// instanceMetrics is the instances with their label values, and the metrics labeled by the instance label names.
+// It's never modified after created, but replaced by refreshInstances, so that the workers of a scrape still
+// running after the deadline keep the one they captured, the next scrape doesn't wait for them, and Describe
+// doesn't wait for the scrape in progress.
+type instanceMetrics struct {
+	labelNames          []string
+	instances           map[futureKey][]string
+	infoDescs           map[int]*infoDesc
 	scrapeDuration      *prometheus.HistogramVec
 	scrapeErrors        *prometheus.CounterVec
 	scrapeLastError     *prometheus.GaugeVec
 	scrapeCount         *prometheus.CounterVec
+	scrapeTimeout       *prometheus.GaugeVec
 	up                  *prometheus.GaugeVec
 	keyValues, keySizes *prometheus.GaugeVec
 	ping                *prometheus.CounterVec
-	mutex               *sync.Mutex
-	wg                  sync.WaitGroup
-	done                chan struct{}
+	poolActive          *prometheus.Desc
+	poolIdle            *prometheus.Desc
+	poolDials           *prometheus.Desc
+	slowlogLength       *prometheus.Desc
+	slowlogCount        *prometheus.Desc
+	slowlogDuration     *prometheus.Desc
+	configValue         *prometheus.Desc
+	configInfo          *prometheus.Desc
+	clientIPs           *prometheus.Desc
+	clientDBs           *prometheus.Desc
+	clientCmds          *prometheus.Desc
+	clientIdle          *prometheus.Desc
+	replicationLag      *prometheus.Desc
+}
+
+func NewPikaExporter(dis discovery.Discovery, opt Options) (*exporter, error) {
+	e, err := newExporter(dis, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	e.wg.Add(1)
+	go e.statsKeySpace(opt.StatsClockHour)
+
+	if e.scrapeInterval > 0 {
+		e.wg.Add(1)
+		go e.scrapeLoop(e.scrapeInterval)
+	}
+	return e, nil
 }
 
-func NewPikaExporter(dis discovery.Discovery, namespace string,
-	keyPatterns, keys string, scanCount, statsClockHour int) (*exporter, error) {
+func newExporter(dis discovery.Discovery, opt Options) (*exporter, error) {
 	e := &exporter{
-		dis:       dis,
-		namespace: namespace,
-		mutex:     new(sync.Mutex),
-		done:      make(chan struct{}),
+		dis:             dis,
+		namespace:       opt.Namespace,
+		scanCount:       opt.ScanCount,
+		scrapeInterval:  opt.ScrapeInterval,
+		instanceOptions: make(map[string]*instanceOptions),
+		lastErrors:      make(map[futureKey]map[string]struct{}),
+		slowlogs:        make(map[futureKey]*slowlogState),
+		clientLists:     make(map[futureKey]*clientListStats),
+		binlogFileSizes: make(map[futureKey]binlogFileSize),
+		mutex:           new(sync.Mutex),
+		done:            make(chan struct{}),
+	}
+
+	maxIdle, idleTimeout := opt.PoolMaxIdle, opt.PoolIdleTimeout
+	if maxIdle == 0 {
+		maxIdle = defaultPoolMaxIdle
+	} else if maxIdle < 0 {
+		maxIdle = 0
+	}
+	if idleTimeout <= 0 {
+		idleTimeout = defaultPoolIdleTimeout
 	}
+	e.pools = newClientPools(maxIdle, idleTimeout)
+
+	if e.scrapeConcurrency = opt.ScrapeConcurrency; e.scrapeConcurrency == 0 {
+		e.scrapeConcurrency = defaultScrapeConcurrency
+	}
+	if e.clientListInterval = opt.ClientListInterval; e.clientListInterval <= 0 {
+		e.clientListInterval = defaultClientListInterval
+	}
+	if e.clientListTopN = opt.ClientListTopN; e.clientListTopN <= 0 {
+		e.clientListTopN = defaultClientListTopN
+	}
+	e.binlogFileSize = opt.BinlogFileSize
 
 	var err error
-	if e.keyPatterns, err = parseKeyArg(keyPatterns); err != nil {
+	if e.defaultOptions, err = newInstanceOptions(opt, InstanceOptions{}); err != nil {
 		return nil, err
 	}
-	if e.keys, err = parseKeyArg(keys); err != nil {
-		return nil, err
+	for addr, override := range opt.Instances {
+		if e.instanceOptions[addr], err = newInstanceOptions(opt, override); err != nil {
+			return nil, fmt.Errorf("invalid options of pika instance %s. err:%s", addr, err.Error())
+		}
 	}
-	e.scanCount = scanCount
 
 	e.initMetrics()
-	e.wg.Add(1)
-	go e.statsKeySpace(statsClockHour)
 	return e, nil
 }
 
+func (e *exporter) optionsOf(addr string) *instanceOptions {
+	if opt, ok := e.instanceOptions[addr]; ok {
+		return opt
+	}
+	return e.defaultOptions
+}
+
 func (e *exporter) initMetrics() {
 	e.collectDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
 		Namespace: e.namespace,
@@ -79,8 +173,24 @@ func (e *exporter) initMetrics() {
 		Namespace: e.namespace,
 		Name:      "exporter_collect_count",
 		Help:      "the count of pika-exporter collect"})
-	e.scrapeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	e.queueWait = prometheus.NewHistogram(prometheus.HistogramOpts{
 		Namespace: e.namespace,
+		Name:      "exporter_scrape_queue_wait_seconds",
+		Help:      "the duration of pika scrape waiting for a free worker in seconds",
+		Buckets: []float64{ // 1ms ~ 10s
+			0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 0.75, 1, 2, 5, 10,
+		}})
+	e.snapshotAge = prometheus.NewDesc(prometheus.BuildFQName(e.namespace, "", "exporter_snapshot_age_seconds"),
+		"the age of the metrics snapshot served in seconds, only exported when scrape-interval is set", nil, nil)
+
+	e.instanceMetrics.Store(newInstanceMetrics(e.namespace, nil, make(map[futureKey][]string)))
+}
+
+// newInstanceMetrics creates the metrics labeled by instance, with the instance label names appended.
+func newInstanceMetrics(namespace string, labelNames []string, instances map[futureKey][]string) *instanceMetrics {
+	im := &instanceMetrics{labelNames: labelNames, instances: instances}
+	im.scrapeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
 		Name:      "exporter_scrape_duration_seconds",
 		Help:      "the each of pika scrape duration in seconds",
 		Buckets: []float64{ // 1ms ~ 10s
@@ -90,52 +200,150 @@ func (e *exporter) initMetrics() {
 			0.25, 0.5, 0.75,
 			1, 2, 5, 10,
 		},
-	}, []string{metrics.LabelNameAddr, metrics.LabelNameAlias})
-	e.scrapeErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace: e.namespace,
+	}, im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias))
+	im.scrapeErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
 		Name:      "exporter_scrape_errors",
 		Help:      "the each of pika scrape error count",
-	}, []string{metrics.LabelNameAddr, metrics.LabelNameAlias})
-	e.scrapeLastError = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: e.namespace,
+	}, im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias))
+	im.scrapeLastError = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
 		Name:      "exporter_last_scrape_error",
 		Help:      "the each of pika scrape last error",
-	}, []string{metrics.LabelNameAddr, metrics.LabelNameAlias, "error"})
-	e.scrapeCount = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace: e.namespace,
+	}, im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias, "error"))
+	im.scrapeCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
 		Name:      "exporter_scrape_count",
 		Help:      "the each of pika scrape count",
-	}, []string{metrics.LabelNameAddr, metrics.LabelNameAlias})
-	e.up = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: e.namespace,
+	}, im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias))
+	im.scrapeTimeout = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "scrape_timeout",
+		Help:      "the each of pika scrape timed out or not before the deadline of the scrape",
+	}, im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias))
+	im.up = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
 		Name:      "up",
 		Help:      "the each of pika connection status",
-	}, []string{metrics.LabelNameAddr, metrics.LabelNameAlias})
+	}, im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias))
 
-	e.keyValues = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: e.namespace,
+	im.keyValues = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
 		Name:      "key_value",
-	}, []string{"addr", "alias", "db", "key", "key_value"})
-	e.keySizes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: e.namespace,
+	}, im.withLabelNames("addr", "alias", "db", "key", "key_value"))
+	im.keySizes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
 		Name:      "key_size",
-	}, []string{"addr", "alias", "db", "key", "key_type"})
-	e.ping = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace: e.namespace,
+	}, im.withLabelNames("addr", "alias", "db", "key", "key_type"))
+	im.ping = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
 		Name:      "ping",
 		Help:      "ping error count",
-	}, []string{metrics.LabelNameAddr, metrics.LabelNameAlias, metrics.LabelNameMethod, metrics.LabelNameType})
+	}, im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias, metrics.LabelNameMethod, metrics.LabelNameType))
+
+	im.poolActive = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "exporter_pool_active_connections"),
+		"the each of pika connection pool active connections, including the idle ones",
+		im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias), nil)
+	im.poolIdle = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "exporter_pool_idle_connections"),
+		"the each of pika connection pool idle connections",
+		im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias), nil)
+	im.poolDials = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "exporter_pool_dial_count"),
+		"the each of pika connection pool dial count",
+		im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias), nil)
+
+	im.slowlogLength = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "slowlog_length"),
+		"the each of pika count of the entries in the slowlog",
+		im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias), nil)
+	im.slowlogCount = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "slowlog_count"),
+		"the each of pika count of the slowlog entries of each command seen by the exporter",
+		im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias, "command"), nil)
+	im.slowlogDuration = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "slowlog_duration_seconds"),
+		"the each of pika duration of the slowlog entries of each command seen by the exporter in seconds",
+		im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias, "command"), nil)
+
+	im.configValue = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "config_value"),
+		"the each of pika value of the numeric parameter of CONFIG GET",
+		im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias, "parameter"), nil)
+	im.configInfo = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "config_info"),
+		"the each of pika value of the non-numeric parameter of CONFIG GET",
+		im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias, "parameter", "value"), nil)
+
+	im.clientIPs = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "client_connections"),
+		"the each of pika count of the connections of the client IPs with the most connections, the others are the IP other",
+		im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias, "ip"), nil)
+	im.clientDBs = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "client_connections_by_db"),
+		"the each of pika count of the client connections of each db",
+		im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias, "db"), nil)
+	im.clientCmds = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "client_connections_by_cmd"),
+		"the each of pika count of the client connections of each last command",
+		im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias, "cmd"), nil)
+	im.clientIdle = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "client_idle_seconds"),
+		"the each of pika idle time of the client connections in seconds",
+		im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias), nil)
+
+	im.replicationLag = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "replication_lag_bytes"),
+		"the each of pika slave binlog lag in bytes of each db behind the master scraped at the same time",
+		im.withLabelNames(metrics.LabelNameAddr, metrics.LabelNameAlias, "master_addr", "db"), nil)
+
+	// the descriptors of all the registered INFO metadata are built with the instance label names,
+	// so that they are not built for every metric of every scrape.
+	im.infoDescs = make(map[int]*infoDesc)
+	for _, mc := range metrics.MetricConfigs {
+		mc.Lookup(func(m metrics.MetaData) {
+			if m.ID() != 0 {
+				im.infoDescs[m.ID()] = newInfoDesc(namespace, m, im.labelNames)
+			}
+		})
+	}
+	return im
+}
+
+func (im *instanceMetrics) withLabelNames(names ...string) []string {
+	return append(names, im.labelNames...)
+}
+
+// labelValues returns the label values of the instance's metric: addr, alias, values and the instance labels.
+func (im *instanceMetrics) labelValues(addr, alias string, values ...string) []string {
+	labelValues := make([]string, 0, 2+len(values)+len(im.labelNames))
+	labelValues = append(labelValues, addr, alias)
+	labelValues = append(labelValues, values...)
+	return append(labelValues, im.instances[futureKey{addr: addr, alias: alias}]...)
+}
+
+// currentInstanceMetrics returns the instance metrics stored by the last refreshInstances.
+func (e *exporter) currentInstanceMetrics() *instanceMetrics {
+	return e.instanceMetrics.Load().(*instanceMetrics)
 }
 
 func (e *exporter) Close() error {
 	close(e.done)
 	e.wg.Wait()
-	return nil
+
+	// the workers are only started by collect holding the mutex, they must not be added while waiting for them.
+	e.mutex.Lock()
+	e.inflight.Wait()
+	e.mutex.Unlock()
+	return e.pools.Close()
 }
 
 func (e *exporter) Describe(ch chan<- *prometheus.Desc) {
+	// the metrics are described with the label names of the instances of the last scrape, without waiting
+	// for the scrape in progress.
+	im := e.currentInstanceMetrics()
+
+	// the INFO metrics are described by the same descriptors as collected, with the instance labels appended.
+	// The metrics with the same name are declared the same by the metric configs, see metrics.CheckConflicts
+	described := make(map[string]struct{})
 	describer := metrics.DescribeFunc(func(m metrics.MetaData) {
-		ch <- prometheus.NewDesc(prometheus.BuildFQName(e.namespace, "", m.Name), m.Help, m.Labels, nil)
+		if _, ok := described[m.Name]; ok {
+			return
+		}
+		described[m.Name] = struct{}{}
+		d, ok := im.infoDescs[m.ID()]
+		if !ok {
+			d = newInfoDesc(e.namespace, m, im.labelNames)
+		}
+		ch <- d.desc
 	})
 	for _, metric := range metrics.MetricConfigs {
 		metric.Desc(describer)
@@ -143,20 +351,57 @@ func (e *exporter) Describe(ch chan<- *prometheus.Desc) {
 
 	ch <- e.collectDuration.Desc()
 	ch <- e.collectCount.Desc()
+	ch <- e.queueWait.Desc()
+
+	im.scrapeDuration.Describe(ch)
+	im.scrapeErrors.Describe(ch)
+	im.scrapeLastError.Describe(ch)
+	im.scrapeCount.Describe(ch)
+	im.scrapeTimeout.Describe(ch)
+
+	im.up.Describe(ch)
+
+	im.keyValues.Describe(ch)
+	im.keySizes.Describe(ch)
+	im.ping.Describe(ch)
 
-	e.scrapeDuration.Describe(ch)
-	e.scrapeErrors.Describe(ch)
-	e.scrapeLastError.Describe(ch)
-	e.scrapeCount.Describe(ch)
+	ch <- im.poolActive
+	ch <- im.poolIdle
+	ch <- im.poolDials
 
-	e.up.Describe(ch)
+	ch <- im.slowlogLength
+	ch <- im.slowlogCount
+	ch <- im.slowlogDuration
 
-	e.keyValues.Describe(ch)
-	e.keySizes.Describe(ch)
-	e.ping.Describe(ch)
+	ch <- im.configValue
+	ch <- im.configInfo
+
+	ch <- im.clientIPs
+	ch <- im.clientDBs
+	ch <- im.clientCmds
+	ch <- im.clientIdle
+
+	ch <- im.replicationLag
+
+	if e.scrapeInterval > 0 {
+		ch <- e.snapshotAge
+	}
 }
 
 func (e *exporter) Collect(ch chan<- prometheus.Metric) {
+	e.collectContext(context.Background(), ch)
+}
+
+// collectContext collects the metrics of the instances finished before the deadline of ctx.
+func (e *exporter) collectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	if e.scrapeInterval > 0 {
+		e.collectSnapshot(ch)
+		return
+	}
+	e.collect(ctx, ch)
+}
+
+func (e *exporter) collect(ctx context.Context, ch chan<- prometheus.Metric) {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 
@@ -168,60 +413,259 @@ func (e *exporter) Collect(ch chan<- prometheus.Metric) {
 		ch <- e.collectDuration
 	}()
 
-	e.keySizes.Reset()
-	e.keyValues.Reset()
+	im := e.scrape(ctx, ch)
+	ch <- e.queueWait
 
-	e.scrape(ch)
+	im.scrapeDuration.Collect(ch)
+	im.scrapeErrors.Collect(ch)
+	im.scrapeLastError.Collect(ch)
+	im.scrapeCount.Collect(ch)
+	im.scrapeTimeout.Collect(ch)
 
-	e.scrapeDuration.Collect(ch)
-	e.scrapeErrors.Collect(ch)
-	e.scrapeLastError.Collect(ch)
-	e.scrapeCount.Collect(ch)
+	im.up.Collect(ch)
 
-	e.up.Collect(ch)
+	im.keySizes.Collect(ch)
+	im.keyValues.Collect(ch)
+	im.ping.Collect(ch)
+
+	for k, stats := range e.pools.stats() {
+		if _, ok := im.instances[k]; !ok {
+			continue
+		}
+		labelValues := im.labelValues(k.addr, k.alias)
+		ch <- prometheus.MustNewConstMetric(im.poolActive, prometheus.GaugeValue, float64(stats.ActiveCount), labelValues...)
+		ch <- prometheus.MustNewConstMetric(im.poolIdle, prometheus.GaugeValue, float64(stats.IdleCount), labelValues...)
+		ch <- prometheus.MustNewConstMetric(im.poolDials, prometheus.CounterValue, float64(stats.dials), labelValues...)
+	}
+}
 
-	e.keySizes.Collect(ch)
-	e.keyValues.Collect(ch)
-	e.ping.Collect(ch)
+// instanceResult is the result of scraping an instance, the metrics are sent only if the instance
+// is finished before the deadline of the scrape.
+type instanceResult struct {
+	key      futureKey
+	up       bool
+	err      error
+	metrics  []prometheus.Metric
+	binlog   *binlogPositions
+	duration time.Duration
 }
 
-func (e *exporter) scrape(ch chan<- prometheus.Metric) {
+// scrape scrapes all the instances by at most scrapeConcurrency workers until the deadline of ctx.
+// The instances not finished by then are marked by scrape_timeout, the results of the others are still sent.
+// It returns the instance metrics the instances are scraped with.
+func (e *exporter) scrape(ctx context.Context, ch chan<- prometheus.Metric) *instanceMetrics {
 	startTime := time.Now()
 
-	fut := newFuture()
-	for _, instance := range e.dis.GetInstances() {
-		fut.Add()
-		go func(addr, password, alias string) {
-			e.scrapeCount.WithLabelValues(addr, alias).Inc()
-			defer func() {
-				e.scrapeDuration.WithLabelValues(addr, alias).Observe(time.Since(startTime).Seconds())
-			}()
+	instances := e.dis.GetInstances()
+	e.refreshInstances(instances)
+	im := e.currentInstanceMetrics()
+	im.keySizes.Reset()
+	im.keyValues.Reset()
+
+	// the instances are queued from a different one every scrape, so that the same instances are not
+	// always the last ones, and timed out when there are more instances than the workers.
+	e.scrapeRound++
+	queue := make(chan discovery.Instance, len(instances))
+	pending := make(map[futureKey]int, len(instances))
+	for i := range instances {
+		instance := instances[(i+e.scrapeRound)%len(instances)]
+		k := futureKey{addr: instance.Addr, alias: instance.Alias}
+		pending[k]++
+		im.scrapeCount.WithLabelValues(im.labelValues(k.addr, k.alias)...).Inc()
+		queue <- instance
+	}
+	close(queue)
 
-			c, err := newClient(addr, password, alias)
-			if err != nil {
-				e.up.WithLabelValues(addr, alias).Set(0)
-
-				fut.Done(futureKey{addr: addr, alias: alias},
-					fmt.Errorf("exporter::scrape new pika client failed. err:%s", err.Error()))
-			} else {
-				defer c.Close()
-				e.up.WithLabelValues(addr, alias).Set(1)
-
-				fut.Add()
-				fut.Add()
-				fut.Done(futureKey{addr: c.Addr(), alias: c.Alias()}, e.collectInfo(c, ch))
-				fut.Done(futureKey{addr: c.Addr(), alias: c.Alias()}, e.collectKeys(c))
-				fut.Done(futureKey{addr: c.Addr(), alias: c.Alias()}, e.collectPing(c))
+	workers := e.scrapeConcurrency
+	if workers < 0 || workers > len(instances) {
+		workers = len(instances)
+	}
+	results := make(chan *instanceResult, len(instances))
+	for i := 0; i < workers; i++ {
+		e.inflight.Add(1)
+		go func() {
+			defer e.inflight.Done()
+			for instance := range queue {
+				// the instances still queued at the deadline are not scraped at all.
+				if deadlineExceeded(ctx) {
+					continue
+				}
+				e.queueWait.Observe(time.Since(startTime).Seconds())
+
+				k := futureKey{addr: instance.Addr, alias: instance.Alias}
+				results <- e.scrapeInstance(ctx, im, time.Now(), k, instance.Password)
+			}
+		}()
+	}
+
+	positions := make(map[futureKey]*binlogPositions)
+wait:
+	for len(pending) > 0 {
+		select {
+		case r := <-results:
+			if deadlineExceeded(ctx) {
+				break wait
+			}
+			if pending[r.key]--; pending[r.key] == 0 {
+				delete(pending, r.key)
+			}
+			e.handleResult(im, r, ch)
+			if r.binlog != nil {
+				positions[r.key] = r.binlog
 			}
-		}(instance.Addr, instance.Password, instance.Alias)
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	// the result received when the deadline is exceeded is not reliable, since the commands time out at the deadline.
+	for k := range pending {
+		im.scrapeTimeout.WithLabelValues(im.labelValues(k.addr, k.alias)...).Set(1)
+		im.scrapeDuration.WithLabelValues(im.labelValues(k.addr, k.alias)...).Observe(time.Since(startTime).Seconds())
+
+		log.Errorf("exporter::scrape collect pika timed out. pika server:%#v elapsed:%s", k, time.Since(startTime))
+	}
+
+	e.collectReplicationLag(im, positions, ch)
+	return im
+}
+
+func (e *exporter) scrapeInstance(ctx context.Context, im *instanceMetrics, startTime time.Time, k futureKey,
+	password string) *instanceResult {
+	r := &instanceResult{key: k}
+	defer func() {
+		r.duration = time.Since(startTime)
+	}()
+
+	opt := e.optionsOf(k.addr)
+	c, err := e.pools.get(ctx, k.addr, password, k.alias, opt.timeout)
+	if err != nil {
+		r.err = fmt.Errorf("exporter::scrape new pika client failed. err:%s", err.Error())
+		return r
+	}
+	defer c.Close()
+	r.up = true
+
+	if opt.collectors[CollectorInfo] {
+		r.metrics, r.binlog, r.err = e.collectInfo(c, im, opt.collectors[CollectorReplicationLag])
+	}
+	if opt.collectors[CollectorKeys] {
+		if err := e.collectKeys(c, im, opt); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+	if opt.collectors[CollectorSlowlog] {
+		promMetrics, err := e.collectSlowlog(c, im)
+		if err != nil && r.err == nil {
+			r.err = err
+		}
+		r.metrics = append(r.metrics, promMetrics...)
+	}
+	if opt.collectors[CollectorConfig] {
+		promMetrics, err := e.collectConfig(c, im, opt)
+		if err != nil && r.err == nil {
+			r.err = err
+		}
+		r.metrics = append(r.metrics, promMetrics...)
+	}
+	if opt.collectors[CollectorClients] {
+		promMetrics, err := e.collectClientList(c, im)
+		if err != nil && r.err == nil {
+			r.err = err
+		}
+		r.metrics = append(r.metrics, promMetrics...)
+	}
+	if err := e.collectPing(c, im, opt); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r
+}
+
+func (e *exporter) handleResult(im *instanceMetrics, r *instanceResult, ch chan<- prometheus.Metric) {
+	k := r.key
+	im.scrapeTimeout.WithLabelValues(im.labelValues(k.addr, k.alias)...).Set(0)
+	im.scrapeDuration.WithLabelValues(im.labelValues(k.addr, k.alias)...).Observe(r.duration.Seconds())
+	if r.up {
+		im.up.WithLabelValues(im.labelValues(k.addr, k.alias)...).Set(1)
+	} else {
+		im.up.WithLabelValues(im.labelValues(k.addr, k.alias)...).Set(0)
 	}
 
-	for k, v := range fut.Wait() {
-		if v != nil {
-			e.scrapeErrors.WithLabelValues(k.addr, k.alias).Inc()
-			e.scrapeLastError.WithLabelValues(k.addr, k.alias, v.Error()).Set(0)
+	for _, m := range r.metrics {
+		ch <- m
+	}
 
-			log.Errorf("exporter::scrape collect pika failed. pika server:%#v err:%s", k, v.Error())
+	if r.err != nil {
+		im.scrapeErrors.WithLabelValues(im.labelValues(k.addr, k.alias)...).Inc()
+		im.scrapeLastError.WithLabelValues(im.labelValues(k.addr, k.alias, r.err.Error())...).Set(0)
+		if _, ok := e.lastErrors[k]; !ok {
+			e.lastErrors[k] = make(map[string]struct{})
+		}
+		e.lastErrors[k][r.err.Error()] = struct{}{}
+
+		log.Errorf("exporter::scrape collect pika failed. pika server:%#v err:%s", k, r.err.Error())
+	}
+}
+
+// refreshInstances updates the instances and their labels from discovery. The series of the instances
+// which are no longer returned by discovery, or whose labels are changed, are deleted, otherwise they
+// would be exported forever with their last values. The instance metrics are replaced instead of modified,
+// since the instances timed out in the last scrape may be still running, and reading them.
+func (e *exporter) refreshInstances(instances []discovery.Instance) {
+	labelNames := instanceLabelNames(instances)
+	current := make(map[futureKey][]string, len(instances))
+	for _, instance := range instances {
+		current[futureKey{addr: instance.Addr, alias: instance.Alias}] = instanceLabelValues(labelNames, instance.Labels)
+	}
+	im := e.currentInstanceMetrics()
+	if equalStrings(labelNames, im.labelNames) && equalInstances(current, im.instances) {
+		return
+	}
+
+	if !equalStrings(labelNames, im.labelNames) {
+		log.Infof("exporter::refreshInstances instance label names changed. old:%v new:%v", im.labelNames, labelNames)
+
+		e.lastErrors = make(map[futureKey]map[string]struct{})
+		e.instanceMetrics.Store(newInstanceMetrics(e.namespace, labelNames, current))
+		return
+	}
+
+	for k, labelValues := range im.instances {
+		if newLabelValues, ok := current[k]; ok && equalStrings(labelValues, newLabelValues) {
+			continue
+		}
+
+		e.deleteInstanceSeries(im, k)
+		log.Infof("exporter::refreshInstances pika server removed or relabeled. pika server:%#v labels:%v", k, labelValues)
+	}
+	next := *im
+	next.instances = current
+	e.instanceMetrics.Store(&next)
+}
+
+func (e *exporter) deleteInstanceSeries(im *instanceMetrics, k futureKey) {
+	e.pools.remove(k)
+	im.up.DeleteLabelValues(im.labelValues(k.addr, k.alias)...)
+	im.scrapeDuration.DeleteLabelValues(im.labelValues(k.addr, k.alias)...)
+	im.scrapeErrors.DeleteLabelValues(im.labelValues(k.addr, k.alias)...)
+	im.scrapeCount.DeleteLabelValues(im.labelValues(k.addr, k.alias)...)
+	im.scrapeTimeout.DeleteLabelValues(im.labelValues(k.addr, k.alias)...)
+	for errString := range e.lastErrors[k] {
+		im.scrapeLastError.DeleteLabelValues(im.labelValues(k.addr, k.alias, errString)...)
+	}
+	delete(e.lastErrors, k)
+	e.slowlogMutex.Lock()
+	delete(e.slowlogs, k)
+	e.slowlogMutex.Unlock()
+	e.clientListMutex.Lock()
+	delete(e.clientLists, k)
+	e.clientListMutex.Unlock()
+	e.binlogMutex.Lock()
+	delete(e.binlogFileSizes, k)
+	e.binlogMutex.Unlock()
+	for _, method := range pingMethods {
+		for _, keyType := range pingTypes {
+			im.ping.DeleteLabelValues(im.labelValues(k.addr, k.alias, method, keyType)...)
 		}
 	}
 }
@@ -235,21 +679,21 @@ const (
 )
 
 var (
-	keyPingString string
-	keyPingHash   string
-	keyPingList   string
-	keyPingSet    string
-	keyPingZset   string
+	pingMethods = []string{"write", "read"}
+	pingTypes   = []string{keyTypeString, keyTypeHash, keyTypeList, keyTypeSet, keyTypeZSet}
 )
 
-func (e *exporter) collectPing(c *client) error {
+func (e *exporter) collectPing(c *client, im *instanceMetrics, opt *instanceOptions) error {
+	if !opt.collectors[CollectorPing] {
+		return nil
+	}
 
 	ts := strconv.FormatInt(time.Now().Unix(), 10)
-	keyPingString = prefixString + ts
-	keyPingHash = prefixHash + ts
-	keyPingList = prefixList + ts
-	keyPingSet = prefixSet + ts
-	keyPingZset = prefixZset + ts
+	keyPingString := prefixString + ts
+	keyPingHash := prefixHash + ts
+	keyPingList := prefixList + ts
+	keyPingSet := prefixSet + ts
+	keyPingZset := prefixZset + ts
 
 	defer func() {
 		_, err := c.Del(keyPingString, keyPingHash, keyPingList, keyPingSet, keyPingZset)
@@ -261,99 +705,123 @@ func (e *exporter) collectPing(c *client) error {
 
 	// write
 	_, err := c.Set(keyPingString, keyPingString)
-	if err != nil {
-		e.ping.WithLabelValues(c.Addr(), c.Alias(), "write", "string").Inc()
+	if err != nil && !c.expired() {
+		im.ping.WithLabelValues(im.labelValues(c.Addr(), c.Alias(), "write", "string")...).Inc()
 		log.Warnf("set %s %s to %s(%s) fail, err:%s", keyPingString, keyPingString, c.Addr(), c.Alias(), err.Error())
 	}
 	_, err = c.Hset(keyPingHash, keyPingHash, keyPingHash)
-	if err != nil {
-		e.ping.WithLabelValues(c.Addr(), c.Alias(), "write", "hash").Inc()
+	if err != nil && !c.expired() {
+		im.ping.WithLabelValues(im.labelValues(c.Addr(), c.Alias(), "write", "hash")...).Inc()
 		log.Warnf("hset %s %s %s to %s(%s) fail, err:%s", keyPingHash, keyPingHash, keyPingHash, c.Addr(), c.Alias(), err.Error())
 	}
 	_, err = c.Lpush(keyPingList, keyPingList)
-	if err != nil {
-		e.ping.WithLabelValues(c.Addr(), c.Alias(), "write", "list").Inc()
+	if err != nil && !c.expired() {
+		im.ping.WithLabelValues(im.labelValues(c.Addr(), c.Alias(), "write", "list")...).Inc()
 		log.Warnf("lpush %s %s to %s(%s) fail, err:%s", keyPingList, keyPingList, c.Addr(), c.Alias(), err.Error())
 	}
 	_, err = c.Sadd(keyPingSet, keyPingSet)
-	if err != nil {
-		e.ping.WithLabelValues(c.Addr(), c.Alias(), "write", "set").Inc()
+	if err != nil && !c.expired() {
+		im.ping.WithLabelValues(im.labelValues(c.Addr(), c.Alias(), "write", "set")...).Inc()
 		log.Warnf("sadd %s %s to %s(%s) fail, err:%s", keyPingSet, keyPingSet, c.Addr(), c.Alias(), err.Error())
 	}
 	_, err = c.Zadd(keyPingZset, 10, keyPingZset)
-	if err != nil {
-		e.ping.WithLabelValues(c.Addr(), c.Alias(), "write", "zset").Inc()
+	if err != nil && !c.expired() {
+		im.ping.WithLabelValues(im.labelValues(c.Addr(), c.Alias(), "write", "zset")...).Inc()
 		log.Warnf("zadd %s 10 %s to %s(%s) fail, err:%s", keyPingZset, keyPingZset, c.Addr(), c.Alias(), err.Error())
 	}
 
 	// read
 	_, err = c.Get(keyPingString)
-	if err != nil && err != redis.ErrNil {
-		e.ping.WithLabelValues(c.Addr(), c.Alias(), "read", "string").Inc()
+	if err != nil && err != redis.ErrNil && !c.expired() {
+		im.ping.WithLabelValues(im.labelValues(c.Addr(), c.Alias(), "read", "string")...).Inc()
 		log.Warnf("get %s from %s(%s) fail, err:%s", keyPingString, c.Addr(), c.Alias(), err.Error())
 	}
 	_, err = c.Hget(keyPingHash, keyPingHash)
-	if err != nil && err != redis.ErrNil {
-		e.ping.WithLabelValues(c.Addr(), c.Alias(), "read", "hash").Inc()
+	if err != nil && err != redis.ErrNil && !c.expired() {
+		im.ping.WithLabelValues(im.labelValues(c.Addr(), c.Alias(), "read", "hash")...).Inc()
 		log.Warnf("hget %s %s from %s(%s) fail, err:%s", keyPingHash, keyPingHash, c.Addr(), c.Alias(), err.Error())
 	}
 	_, err = c.Lrange(keyPingList, 0, 1)
-	if err != nil {
-		e.ping.WithLabelValues(c.Addr(), c.Alias(), "read", "list").Inc()
+	if err != nil && !c.expired() {
+		im.ping.WithLabelValues(im.labelValues(c.Addr(), c.Alias(), "read", "list")...).Inc()
 		log.Warnf("lrange %s 0 1 from %s(%s) fail, err:%s", keyPingList, c.Addr(), c.Alias(), err.Error())
 	}
 	_, err = c.Scard(keyPingSet)
-	if err != nil {
-		e.ping.WithLabelValues(c.Addr(), c.Alias(), "read", "set").Inc()
+	if err != nil && !c.expired() {
+		im.ping.WithLabelValues(im.labelValues(c.Addr(), c.Alias(), "read", "set")...).Inc()
 		log.Warnf("scard %s from %s(%s) fail, err:%s", keyPingSet, c.Addr(), c.Alias(), err.Error())
 	}
 	_, err = c.Zcard(keyPingZset)
-	if err != nil {
-		e.ping.WithLabelValues(c.Addr(), c.Alias(), "read", "zset").Inc()
+	if err != nil && !c.expired() {
+		im.ping.WithLabelValues(im.labelValues(c.Addr(), c.Alias(), "read", "zset")...).Inc()
 		log.Warnf("zcard %s from %s(%s) fail, err:%s", keyPingZset, c.Addr(), c.Alias(), err.Error())
 	}
 	return nil
 }
 
-func (e *exporter) collectInfo(c *client, ch chan<- prometheus.Metric) error {
+// collectInfo returns the metrics of INFO of the instance, and the binlog positions for the replication lag
+// if replicationLag.
+func (e *exporter) collectInfo(c *client, im *instanceMetrics, replicationLag bool) ([]prometheus.Metric,
+	*binlogPositions, error) {
 	info, err := c.Info()
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	parseOpt, err := parseInfo(info)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	version, extracts, err := parseInfo(info)
+	promMetrics, err := e.parsedInfoMetrics(im, parseOpt, c.Addr(), c.Alias())
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	extracts[metrics.LabelNameAddr] = c.Addr()
-	extracts[metrics.LabelNameAlias] = c.Alias()
+	if !replicationLag {
+		return promMetrics, nil, nil
+	}
+	return promMetrics, e.binlogPositions(c, parseOpt), nil
+}
+
+// infoMetrics parses INFO of the instance into the metrics of all the metric configs.
+func (e *exporter) infoMetrics(info, addr, alias string) ([]prometheus.Metric, error) {
+	parseOpt, err := parseInfo(info)
+	if err != nil {
+		return nil, err
+	}
+	return e.parsedInfoMetrics(e.currentInstanceMetrics(), parseOpt, addr, alias)
+}
+
+func (e *exporter) parsedInfoMetrics(im *instanceMetrics, parseOpt metrics.ParseOption,
+	addr, alias string) ([]prometheus.Metric, error) {
+	parseOpt.Extracts[metrics.LabelNameAddr] = addr
+	parseOpt.Extracts[metrics.LabelNameAlias] = alias
+	instanceLabelValues := im.instances[futureKey{addr: addr, alias: alias}]
 
+	var promMetrics []prometheus.Metric
 	collector := metrics.CollectFunc(func(m metrics.Metric) error {
-		promMetric, err := prometheus.NewConstMetric(
-			prometheus.NewDesc(prometheus.BuildFQName(e.namespace, "", m.Name), m.Help, m.Labels, nil),
-			m.MetricsType(), m.Value, m.LabelValues...)
+		d, ok := im.infoDescs[m.ID()]
+		if !ok {
+			d = newInfoDesc(e.namespace, m.MetaData, im.labelNames)
+		}
+		promMetric, err := prometheus.NewConstMetric(d.desc, m.MetricsType(), m.Value,
+			d.labelValues(m.LabelValues, instanceLabelValues)...)
 		if err != nil {
 			return err
 		}
 
-		ch <- promMetric
+		promMetrics = append(promMetrics, promMetric)
 		return nil
 	})
-	parseOpt := metrics.ParseOption{
-		Version:  version,
-		Extracts: extracts,
-		Info:     info,
-	}
 	for _, m := range metrics.MetricConfigs {
 		m.Parse(m, collector, parseOpt)
 	}
 
-	return nil
+	return promMetrics, nil
 }
 
-func (e *exporter) collectKeys(c *client) error {
-	allKeys := append([]dbKeyPair{}, e.keys...)
-	keys, err := getKeysFromPatterns(c, e.keyPatterns, e.scanCount)
+func (e *exporter) collectKeys(c *client, im *instanceMetrics, opt *instanceOptions) error {
+	allKeys := append([]dbKeyPair{}, opt.keys...)
+	keys, err := getKeysFromPatterns(c, opt.keyPatterns, e.scanCount)
 	if err != nil {
 		log.Errorf("get keys from patterns failed. addr:%s err:%s", c.Addr(), err.Error())
 	} else {
@@ -362,6 +830,9 @@ func (e *exporter) collectKeys(c *client) error {
 
 	log.Debugf("collectKeys allKeys:%#v", allKeys)
 	for _, k := range allKeys {
+		if c.expired() {
+			return c.ctx.Err()
+		}
 		if err := c.Select(k.db); err != nil {
 			log.Warnf("couldn't select database %s when getting key info. addr:%s", k.db, c.Addr())
 			continue
@@ -373,9 +844,9 @@ func (e *exporter) collectKeys(c *client) error {
 			continue
 		}
 
-		e.keySizes.WithLabelValues(c.Addr(), c.Alias(), "db"+k.db, k.key, keyInfo.keyType).Set(keyInfo.size)
+		im.keySizes.WithLabelValues(im.labelValues(c.Addr(), c.Alias(), "db"+k.db, k.key, keyInfo.keyType)...).Set(keyInfo.size)
 		if value, err := c.Get(k.key); err == nil {
-			e.keyValues.WithLabelValues(c.Addr(), c.Alias(), "db"+k.db, k.key, value).Set(1)
+			im.keyValues.WithLabelValues(im.labelValues(c.Addr(), c.Alias(), "db"+k.db, k.key, value)...).Set(1)
 		}
 	}
 
@@ -424,7 +895,7 @@ func (e *exporter) statsKeySpace(hour int) {
 		}
 
 		for _, v := range e.dis.GetInstances() {
-			c, err := newClient(v.Addr, v.Password, v.Alias)
+			c, err := e.pools.get(context.Background(), v.Addr, v.Password, v.Alias, e.optionsOf(v.Addr).timeout)
 			if err != nil {
 				log.Warnln("stats KeySpace new pika client failed. err:", err)
 				continue
@@ -437,38 +908,6 @@ func (e *exporter) statsKeySpace(hour int) {
 	}
 }
 
-func parseKeyArg(keysArgString string) ([]dbKeyPair, error) {
-	if keysArgString == "" {
-		return nil, nil
-	}
-
-	var (
-		keys []dbKeyPair
-		err  error
-	)
-	for _, k := range strings.Split(keysArgString, ",") {
-		db := "0"
-		key := ""
-		frags := strings.Split(k, "=")
-		switch len(frags) {
-		case 1:
-			db = "0"
-			key, err = url.QueryUnescape(strings.TrimSpace(frags[0]))
-		case 2:
-			db = strings.Replace(strings.TrimSpace(frags[0]), "db", "", -1)
-			key, err = url.QueryUnescape(strings.TrimSpace(frags[1]))
-		default:
-			return keys, fmt.Errorf("invalid key list argument: %s", k)
-		}
-		if err != nil {
-			return keys, fmt.Errorf("couldn't parse db/key string: %s", k)
-		}
-
-		keys = append(keys, dbKeyPair{db, key})
-	}
-	return keys, err
-}
-
 func getClockDuration(hour int) time.Duration {
 	timeNow, timeDst := time.Now(), time.Now()
 	subHour := hour - timeNow.Hour()
@@ -1,9 +1,18 @@
 package exporter
 
 import (
+	"net"
+	"net/http/httptest"
+	"strings"
 	"testing"
-	"github.com/prometheus/client_golang/prometheus"
+	"time"
+
 	"github.com/pourer/pika_exporter/discovery"
+	"github.com/pourer/pika_exporter/exporter/metrics"
+	"github.com/pourer/pika_exporter/exporter/test"
+	"github.com/pourer/pika_exporter/internal/respserver"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,9 +26,150 @@ func (d *fakeDiscovery) GetInstances() []discovery.Instance {
 func TestExporter_Describe(t *testing.T) {
 	assert := assert.New(t)
 
-	e, err := NewPikaExporter(&fakeDiscovery{}, "pika", "", "", 100, 0)
+	e, err := NewPikaExporter(&fakeDiscovery{}, Options{Namespace: "pika", ScanCount: 100, StatsClockHour: 0})
 	assert.NoError(err)
 	defer e.Close()
 
 	assert.NoError(prometheus.Register(e))
 }
+
+func TestExporter_RefreshInstances(t *testing.T) {
+	assert := assert.New(t)
+
+	e, err := NewPikaExporter(&fakeDiscovery{}, Options{Namespace: "pika", ScanCount: 100, StatsClockHour: -1})
+	assert.NoError(err)
+	defer e.Close()
+
+	e.refreshInstances([]discovery.Instance{{Addr: "127.0.0.1:9221"}, {Addr: "127.0.0.1:9222"}})
+	im := e.currentInstanceMetrics()
+	im.up.WithLabelValues("127.0.0.1:9221", "").Set(1)
+	im.up.WithLabelValues("127.0.0.1:9222", "").Set(0)
+	im.ping.WithLabelValues("127.0.0.1:9222", "", "write", "hash").Inc()
+	assert.Equal(2, testutil.CollectAndCount(im.up))
+
+	e.refreshInstances([]discovery.Instance{{Addr: "127.0.0.1:9221"}})
+	assert.Equal(1, testutil.CollectAndCount(e.currentInstanceMetrics().up))
+	assert.Equal(0, testutil.CollectAndCount(e.currentInstanceMetrics().ping))
+}
+
+func TestExporter_RefreshInstancesInflight(t *testing.T) {
+	assert := assert.New(t)
+
+	e, err := NewPikaExporter(&fakeDiscovery{}, Options{Namespace: "pika", ScanCount: 100, StatsClockHour: -1})
+	assert.NoError(err)
+	defer e.Close()
+
+	e.refreshInstances([]discovery.Instance{{Addr: "127.0.0.1:9221", Labels: map[string]string{"cluster": "c1"}}})
+	im := e.currentInstanceMetrics()
+
+	// a worker timed out in the last scrape is still running, the refresh doesn't wait for it.
+	e.inflight.Add(1)
+	refreshed := make(chan struct{})
+	go func() {
+		defer close(refreshed)
+		e.refreshInstances([]discovery.Instance{{Addr: "127.0.0.1:9221", Labels: map[string]string{"idc": "bj"}}})
+	}()
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("refreshInstances waits for the running workers")
+	}
+
+	// the running worker keeps the instance metrics it captured.
+	assert.Equal([]string{"cluster"}, im.labelNames)
+	assert.NotPanics(func() {
+		prometheus.MustNewConstMetric(im.slowlogLength, prometheus.GaugeValue, 1, im.labelValues("127.0.0.1:9221", "")...)
+	})
+	assert.Equal([]string{"idc"}, e.currentInstanceMetrics().labelNames)
+	assert.Equal([]string{"127.0.0.1:9221", "", "bj"}, e.currentInstanceMetrics().labelValues("127.0.0.1:9221", ""))
+	e.inflight.Done()
+}
+
+func TestExporter_InstanceLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	e, err := NewPikaExporter(&fakeDiscovery{}, Options{Namespace: "pika", ScanCount: 100, StatsClockHour: -1})
+	assert.NoError(err)
+	defer e.Close()
+
+	e.refreshInstances([]discovery.Instance{
+		{Addr: "127.0.0.1:9221", Labels: map[string]string{"cluster": "c1", "shard": "1", "addr": "dropped"}},
+		{Addr: "127.0.0.1:9222", Labels: map[string]string{"cluster": "c1", "idc": "bj"}},
+	})
+	im := e.currentInstanceMetrics()
+	assert.Equal([]string{"cluster", "idc", "shard"}, im.labelNames)
+	im.up.WithLabelValues(im.labelValues("127.0.0.1:9221", "")...).Set(1)
+	im.up.WithLabelValues(im.labelValues("127.0.0.1:9222", "")...).Set(1)
+	assert.NoError(testutil.CollectAndCompare(im.up, strings.NewReader(`
+# HELP pika_up the each of pika connection status
+# TYPE pika_up gauge
+pika_up{addr="127.0.0.1:9221",alias="",cluster="c1",idc="",shard="1"} 1
+pika_up{addr="127.0.0.1:9222",alias="",cluster="c1",idc="bj",shard=""} 1
+`)))
+
+	d := newInfoDesc(e.namespace, metrics.MetaData{Name: "m", Labels: []string{"addr", "alias", "idc"}}, im.labelNames)
+	assert.Equal(`Desc{fqName: "pika_m", help: "", constLabels: {}, variableLabels: [addr alias idc cluster shard]}`,
+		d.desc.String())
+	assert.Equal([]string{"127.0.0.1:9221", "", "x", "c1", "1"},
+		d.labelValues([]string{"127.0.0.1:9221", "", "x"}, im.instances[futureKey{addr: "127.0.0.1:9221"}]))
+
+	// relabeled instance drops its old series
+	e.refreshInstances([]discovery.Instance{
+		{Addr: "127.0.0.1:9221", Labels: map[string]string{"cluster": "c2", "shard": "1"}},
+		{Addr: "127.0.0.1:9222", Labels: map[string]string{"cluster": "c1", "idc": "bj"}},
+	})
+	assert.Equal(1, testutil.CollectAndCount(e.currentInstanceMetrics().up))
+}
+
+func TestExporter_ReservedInstanceLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	// the instance is the slave of itself, to export the replication lag.
+	var info string
+	s := respserver.New(t, func(args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "INFO":
+			return info
+		case "SLOWLOG":
+			if strings.ToUpper(args[1]) == "LEN" {
+				return 1
+			}
+			return []interface{}{slowlogReply(1, 20000, "GET", "k")}
+		case "CONFIG":
+			if args[2] == binlogFileSizeParameter {
+				return []string{binlogFileSizeParameter, "104857600"}
+			}
+			return []string{"slave-read-only", "yes"}
+		case "CLIENT":
+			return "addr=10.0.0.1:50001 fd=10 name= age=100 idle=0 db=0 cmd=get"
+		}
+		return respserver.Status("OK")
+	})
+	defer s.Close()
+	host, port, _ := net.SplitHostPort(s.Addr())
+	info = strings.NewReplacer("master_host:10.20.30.40", "master_host:"+host,
+		"master_port:9221", "master_port:"+port).Replace(test.V342SlaveInfo)
+
+	// the instance labels with the same names as the labels of the exporter's own metrics are dropped,
+	// instead of the duplicate label names failing the metrics.
+	labels := map[string]string{"cluster": "c1", "command": "dropped", "parameter": "dropped", "value": "dropped",
+		"ip": "dropped", "cmd": "dropped", "master_addr": "dropped"}
+	dis := discovery.NewStaticDiscovery(discovery.Instance{Addr: s.Addr(), Labels: labels})
+	e, err := newExporter(dis, Options{Namespace: "pika",
+		Collectors: []string{CollectorInfo, CollectorSlowlog, CollectorConfig, CollectorClients,
+			CollectorReplicationLag}})
+	assert.NoError(err)
+	defer e.Close()
+
+	w := httptest.NewRecorder()
+	e.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(200, w.Code)
+
+	body := w.Body.String()
+	assert.Contains(body, `pika_slowlog_length{addr="`+s.Addr()+`",alias="",cluster="c1"} 1`)
+	assert.Contains(body, `pika_config_info{addr="`+s.Addr()+`",alias="",cluster="c1",parameter="slave-read-only",value="yes"} 1`)
+	assert.Contains(body, `pika_client_connections{addr="`+s.Addr()+`",alias="",cluster="c1",ip="10.0.0.1"} 1`)
+	assert.Contains(body, `pika_client_connections_by_cmd{addr="`+s.Addr()+`",alias="",cluster="c1",cmd="get"} 1`)
+	assert.Contains(body, `pika_replication_lag_bytes{addr="`+s.Addr()+`",alias="",cluster="c1",db="db1",master_addr="`+s.Addr()+`"} 0`)
+	assert.NotContains(body, "dropped")
+}
@@ -0,0 +1,156 @@
+package exporter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPoolMaxIdle     = 2
+	defaultPoolIdleTimeout = 5 * time.Minute
+	// testOnBorrowIdle is the idle time after which the connection is checked by PING before borrowed,
+	// the connections used recently are not checked, to save a round trip of every scrape.
+	testOnBorrowIdle = time.Minute
+)
+
+type instancePool struct {
+	dials uint64 // accessed atomically, keep it 64-bit aligned
+	*redis.Pool
+	password string
+	timeout  time.Duration
+}
+
+type poolStats struct {
+	redis.PoolStats
+	dials uint64
+}
+
+// clientPools keeps a connection pool for each instance, so that the connections are reused
+// across scrapes, instead of a TCP connect and AUTH on every scrape.
+type clientPools struct {
+	sync.Mutex
+	maxIdle     int
+	idleTimeout time.Duration
+	pools       map[futureKey]*instancePool
+}
+
+func newClientPools(maxIdle int, idleTimeout time.Duration) *clientPools {
+	return &clientPools{
+		maxIdle:     maxIdle,
+		idleTimeout: idleTimeout,
+		pools:       make(map[futureKey]*instancePool),
+	}
+}
+
+// get borrows a connection of the instance, the pool is recreated if the password or timeout is changed.
+// The commands of the returned client are bounded by the deadline of ctx.
+func (p *clientPools) get(ctx context.Context, addr, password, alias string, timeout time.Duration) (*client, error) {
+	k := futureKey{addr: addr, alias: alias}
+
+	p.Lock()
+	pool, ok := p.pools[k]
+	if ok && (pool.password != password || pool.timeout != timeout) {
+		pool.Close()
+		ok = false
+	}
+	if !ok {
+		pool = p.newPool(k, password, timeout)
+		p.pools[k] = pool
+	}
+	p.Unlock()
+
+	conn, err := borrow(ctx, pool.Pool)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(ctx, addr, alias, conn, timeout)
+}
+
+// borrow gets a connection of the pool until the deadline of ctx. The dial and the PING of TestOnBorrow are
+// bounded by the timeout of the instance only, so a pika instance accepting connections but never replying
+// would hold the scrape beyond its deadline. The connection got after the deadline is returned to the pool.
+func borrow(ctx context.Context, pool *redis.Pool) (redis.Conn, error) {
+	if ctx.Done() == nil {
+		return pool.Get(), nil
+	}
+
+	ch := make(chan redis.Conn, 1)
+	go func() {
+		ch <- pool.Get()
+	}()
+	select {
+	case conn := <-ch:
+		return conn, nil
+	case <-ctx.Done():
+		go func() {
+			(<-ch).Close()
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+func (p *clientPools) newPool(k futureKey, password string, timeout time.Duration) *instancePool {
+	pool := &instancePool{
+		password: password,
+		timeout:  timeout,
+	}
+	pool.Pool = &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			atomic.AddUint64(&pool.dials, 1)
+			return dial(k.addr, password, timeout)
+		},
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			if time.Since(t) < testOnBorrowIdle {
+				return nil
+			}
+			_, err := c.Do("PING")
+			if err != nil {
+				log.Warnf("clientPools::TestOnBorrow ping failed, reconnect. addr:%s err:%s", k.addr, err.Error())
+			}
+			return err
+		},
+		MaxIdle:     p.maxIdle,
+		IdleTimeout: p.idleTimeout,
+	}
+	return pool
+}
+
+func (p *clientPools) stats() map[futureKey]poolStats {
+	p.Lock()
+	defer p.Unlock()
+
+	stats := make(map[futureKey]poolStats, len(p.pools))
+	for k, pool := range p.pools {
+		stats[k] = poolStats{
+			PoolStats: pool.Stats(),
+			dials:     atomic.LoadUint64(&pool.dials),
+		}
+	}
+	return stats
+}
+
+func (p *clientPools) remove(k futureKey) {
+	p.Lock()
+	defer p.Unlock()
+
+	if pool, ok := p.pools[k]; ok {
+		pool.Close()
+		delete(p.pools, k)
+	}
+}
+
+func (p *clientPools) Close() error {
+	p.Lock()
+	defer p.Unlock()
+
+	for k, pool := range p.pools {
+		pool.Close()
+		delete(p.pools, k)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pourer/pika_exporter/internal/respserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientPools(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		mu       sync.Mutex
+		commands []string
+	)
+	s := respserver.New(t, func(args []string) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, strings.Join(args, " "))
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			return respserver.Status("PONG")
+		case "SELECT":
+			return respserver.Status("OK")
+		case "AUTH":
+			return errors.New("ERR invalid password")
+		}
+		return "value"
+	})
+	defer s.Close()
+
+	pools := newClientPools(defaultPoolMaxIdle, defaultPoolIdleTimeout)
+	defer pools.Close()
+
+	c, err := pools.get(context.Background(), s.Addr(), "", "a", time.Second)
+	assert.NoError(err)
+	assert.NoError(c.Select("3"))
+	_, err = c.Get("k")
+	assert.NoError(err)
+	assert.NoError(c.Close())
+
+	c, err = pools.get(context.Background(), s.Addr(), "", "a", time.Second)
+	assert.NoError(err)
+	_, err = c.Get("k")
+	assert.NoError(err)
+	assert.NoError(c.Close())
+
+	mu.Lock()
+	// the connection used recently is borrowed without PING
+	assert.Equal([]string{"SELECT 3", "GET k", "SELECT 0", "GET k"}, commands)
+	mu.Unlock()
+
+	stats := pools.stats()[futureKey{addr: s.Addr(), alias: "a"}]
+	assert.EqualValues(1, stats.dials)
+	assert.Equal(1, stats.IdleCount)
+
+	// a changed password recreates the pool
+	c, err = pools.get(context.Background(), s.Addr(), "pwd", "a", time.Second)
+	if assert.Error(err) {
+		assert.Nil(c)
+	}
+}
+
+func TestClientPools_Deadline(t *testing.T) {
+	assert := assert.New(t)
+
+	// the server accepts the connections but never replies, such as AUTH of the dial
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	pools := newClientPools(defaultPoolMaxIdle, defaultPoolIdleTimeout)
+	defer pools.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	startTime := time.Now()
+	c, err := pools.get(ctx, l.Addr().String(), "pwd", "", 5*time.Second)
+	assert.Equal(context.DeadlineExceeded, err)
+	assert.Nil(c)
+	assert.True(time.Since(startTime) < time.Second)
+}
@@ -0,0 +1,162 @@
+package exporter
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pourer/pika_exporter/exporter/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	binlogFileSizeParameter = "binlog-file-size"
+	// binlogFileSizeRetryInterval is the interval of CONFIG GET of the instance which failed it, such as
+	// CONFIG renamed, so that it isn't sent and logged every scrape.
+	binlogFileSizeRetryInterval = 10 * time.Minute
+)
+
+// binlogOffsetReg matches the binlog offsets in INFO, such as `binlog_offset:1844 79515877` of < 3.1.0,
+// `db0:binlog_offset=0 0,safety_purge=none` of 3.1.x and `db0 binlog_offset=9 4096,safety_purge=none` since 3.2.
+var binlogOffsetReg = regexp.MustCompile(`(?m)^(?:(db[\d]+)[:\s]\s*)?binlog_offset[=:]\s*([\d]+)\s+([\d]+)`)
+
+type binlogOffset struct {
+	filenum, offset int64
+}
+
+// parseBinlogOffsets returns the binlog offsets of the dbs in INFO, the offset of < 3.1.0 is of db0.
+func parseBinlogOffsets(info string) map[string]binlogOffset {
+	offsets := make(map[string]binlogOffset)
+	for _, matches := range binlogOffsetReg.FindAllStringSubmatch(info, -1) {
+		db := matches[1]
+		if db == "" {
+			db = "db0"
+		}
+		filenum, err1 := strconv.ParseInt(matches[2], 10, 64)
+		offset, err2 := strconv.ParseInt(matches[3], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		offsets[db] = binlogOffset{filenum: filenum, offset: offset}
+	}
+	return offsets
+}
+
+// binlogPositions are the absolute binlog positions in bytes of the dbs of an instance, and the addr of
+// its master if it's a slave.
+type binlogPositions struct {
+	masterAddr string
+	dbs        map[string]int64
+}
+
+// binlogPositions converts the binlog offsets in INFO of the instance to the absolute positions by the binlog
+// file size, nil if there is no binlog offset or the file size is unknown.
+func (e *exporter) binlogPositions(c *client, opt metrics.ParseOption) *binlogPositions {
+	offsets := parseBinlogOffsets(opt.Info)
+	if len(offsets) == 0 {
+		return nil
+	}
+
+	fileSize, err := e.instanceBinlogFileSize(c)
+	if err != nil {
+		return nil
+	}
+
+	p := &binlogPositions{dbs: make(map[string]int64, len(offsets))}
+	for db, offset := range offsets {
+		p.dbs[db] = offset.filenum*fileSize + offset.offset
+	}
+	masterHost, _ := opt.Lookup("master_host")
+	masterPort, _ := opt.Lookup("master_port")
+	if masterHost != "" && masterPort != "" {
+		p.masterAddr = net.JoinHostPort(masterHost, masterPort)
+	}
+	return p
+}
+
+// binlogFileSize is binlog-file-size of CONFIG GET of an instance, or the error of it which is retried after
+// retryTime.
+type binlogFileSize struct {
+	size      int64
+	err       error
+	retryTime time.Time
+}
+
+// instanceBinlogFileSize returns the configured binlog file size, or binlog-file-size of CONFIG GET of the instance,
+// which is cached since it can't be changed without restarting pika. The error is cached as well, and logged
+// only when CONFIG GET is sent.
+func (e *exporter) instanceBinlogFileSize(c *client) (int64, error) {
+	if e.binlogFileSize > 0 {
+		return e.binlogFileSize, nil
+	}
+
+	k := futureKey{addr: c.Addr(), alias: c.Alias()}
+	e.binlogMutex.Lock()
+	fileSize, ok := e.binlogFileSizes[k]
+	e.binlogMutex.Unlock()
+	if ok && (fileSize.err == nil || time.Now().Before(fileSize.retryTime)) {
+		return fileSize.size, fileSize.err
+	}
+
+	size, err := configBinlogFileSize(c)
+	if err != nil {
+		// the error caused by the deadline of the scrape is not the instance's, it's retried next scrape.
+		if c.expired() {
+			return 0, err
+		}
+		log.Warnf("exporter::instanceBinlogFileSize get binlog file size failed, retry after %s. addr:%s err:%s",
+			binlogFileSizeRetryInterval, c.Addr(), err.Error())
+		fileSize = binlogFileSize{err: err, retryTime: time.Now().Add(binlogFileSizeRetryInterval)}
+	} else {
+		fileSize = binlogFileSize{size: size}
+	}
+
+	e.binlogMutex.Lock()
+	e.binlogFileSizes[k] = fileSize
+	e.binlogMutex.Unlock()
+	return fileSize.size, fileSize.err
+}
+
+func configBinlogFileSize(c *client) (int64, error) {
+	values, err := c.ConfigGet(binlogFileSizeParameter)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(values[binlogFileSizeParameter], 10, 64)
+}
+
+// collectReplicationLag exports the lag in bytes of each db of the slaves whose masters are scraped
+// in the same round, the master is the instance whose addr is master_host:master_port of the slave.
+// The addrs are compared as they are, the host names are not resolved.
+func (e *exporter) collectReplicationLag(im *instanceMetrics, positions map[futureKey]*binlogPositions, ch chan<- prometheus.Metric) {
+	masters := make(map[string]*binlogPositions, len(positions))
+	for k, p := range positions {
+		masters[k.addr] = p
+	}
+
+	for k, p := range positions {
+		if p.masterAddr == "" {
+			continue
+		}
+		master, ok := masters[p.masterAddr]
+		if !ok {
+			continue
+		}
+
+		for db, position := range p.dbs {
+			masterPosition, ok := master.dbs[db]
+			if !ok {
+				continue
+			}
+			// the master is scraped at a different moment, the slave may be ahead of it by then.
+			lag := masterPosition - position
+			if lag < 0 {
+				lag = 0
+			}
+			ch <- prometheus.MustNewConstMetric(im.replicationLag, prometheus.GaugeValue, float64(lag),
+				im.labelValues(k.addr, k.alias, p.masterAddr, db)...)
+		}
+	}
+}
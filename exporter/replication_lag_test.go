@@ -0,0 +1,174 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pourer/pika_exporter/discovery"
+	"github.com/pourer/pika_exporter/exporter/test"
+	"github.com/pourer/pika_exporter/internal/respserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBinlogOffsets(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(map[string]binlogOffset{"db0": {filenum: 1844, offset: 79515877}},
+		parseBinlogOffsets(test.V2233MasterInfo))
+
+	offsets := parseBinlogOffsets(test.V310MasterInfo)
+	assert.Len(offsets, 8)
+	assert.Equal(binlogOffset{}, offsets["db7"])
+
+	assert.Equal(map[string]binlogOffset{
+		"db0": {filenum: 75, offset: 60416},
+		"db1": {filenum: 11, offset: 2097152},
+	}, parseBinlogOffsets(test.V342SlaveInfo))
+}
+
+func newInfoServer(t *testing.T, info string, configGets *int32) *respserver.Server {
+	return respserver.New(t, func(args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "INFO":
+			return info
+		case "CONFIG":
+			atomic.AddInt32(configGets, 1)
+			if strings.EqualFold(args[1], "GET") && args[2] != binlogFileSizeParameter {
+				return []string{}
+			}
+			return []string{binlogFileSizeParameter, "104857600"}
+		}
+		return respserver.Status("OK")
+	})
+}
+
+func TestExporter_ReplicationLag(t *testing.T) {
+	assert := assert.New(t)
+
+	var configGets int32
+	master := newInfoServer(t, test.V342MasterInfo, &configGets)
+	defer master.Close()
+	host, port, _ := net.SplitHostPort(master.Addr())
+	slaveInfo := strings.NewReplacer("master_host:10.20.30.40", "master_host:"+host,
+		"master_port:9221", "master_port:"+port).Replace(test.V342SlaveInfo)
+	slave := newInfoServer(t, slaveInfo, &configGets)
+	defer slave.Close()
+
+	e, err := newExporter(discovery.NewStaticDiscovery(discovery.Instance{Addr: master.Addr()},
+		discovery.Instance{Addr: slave.Addr(), Alias: "s"}),
+		Options{Namespace: "pika", Collectors: []string{CollectorInfo, CollectorReplicationLag}})
+	assert.NoError(err)
+	defer e.Close()
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		e.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		assert.Equal(200, w.Code)
+
+		body := w.Body.String()
+		labels := `addr="` + slave.Addr() + `",alias="s",db="%s",master_addr="` + master.Addr() + `"`
+		assert.Contains(body, fmt.Sprintf(`pika_replication_lag_bytes{`+labels+`} 0`, "db0"))
+		assert.Contains(body, fmt.Sprintf(`pika_replication_lag_bytes{`+labels+`} 1.03809024e+08`, "db1"))
+		assert.NotContains(body, `pika_replication_lag_bytes{addr="`+master.Addr()+`"`)
+	}
+	// binlog-file-size is cached for each instance
+	assert.Equal(int32(2), atomic.LoadInt32(&configGets))
+}
+
+func TestExporter_ReplicationLagBinlogFileSize(t *testing.T) {
+	assert := assert.New(t)
+
+	var configGets int32
+	master := newInfoServer(t, test.V342MasterInfo, &configGets)
+	defer master.Close()
+	host, port, _ := net.SplitHostPort(master.Addr())
+	slaveInfo := strings.NewReplacer("master_host:10.20.30.40", "master_host:"+host,
+		"master_port:9221", "master_port:"+port).Replace(test.V342SlaveInfo)
+	slave := newInfoServer(t, slaveInfo, &configGets)
+	defer slave.Close()
+
+	e, err := newExporter(discovery.NewStaticDiscovery(discovery.Instance{Addr: master.Addr()},
+		discovery.Instance{Addr: slave.Addr()}),
+		Options{Namespace: "pika", Collectors: []string{CollectorInfo, CollectorReplicationLag},
+			BinlogFileSize: 2097152})
+	assert.NoError(err)
+	defer e.Close()
+
+	w := httptest.NewRecorder()
+	e.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(200, w.Code)
+
+	labels := `addr="` + slave.Addr() + `",alias="",db="db1",master_addr="` + master.Addr() + `"`
+	assert.Contains(w.Body.String(), `pika_replication_lag_bytes{`+labels+`} 1.048576e+06`)
+	assert.Equal(int32(0), atomic.LoadInt32(&configGets))
+}
+
+func TestExporter_ReplicationLagDisabled(t *testing.T) {
+	assert := assert.New(t)
+
+	var configGets int32
+	master := newInfoServer(t, test.V342MasterInfo, &configGets)
+	defer master.Close()
+
+	// CONFIG GET binlog-file-size is not sent without the replication_lag collector, even by the config one.
+	e, err := newExporter(discovery.NewStaticDiscovery(discovery.Instance{Addr: master.Addr()}),
+		Options{Namespace: "pika", Collectors: []string{CollectorInfo, CollectorConfig},
+			ConfigParameters: []string{"maxclients"}})
+	assert.NoError(err)
+	defer e.Close()
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		e.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		assert.Equal(200, w.Code)
+		assert.NotContains(w.Body.String(), "pika_replication_lag_bytes{")
+	}
+	assert.Equal(int32(2), atomic.LoadInt32(&configGets))
+}
+
+func TestExporter_ReplicationLagConfigError(t *testing.T) {
+	assert := assert.New(t)
+
+	var configGets int32
+	master := respserver.New(t, func(args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "INFO":
+			return test.V342MasterInfo
+		case "CONFIG":
+			atomic.AddInt32(&configGets, 1)
+			return errors.New("ERR unknown command 'config'")
+		}
+		return respserver.Status("OK")
+	})
+	defer master.Close()
+
+	e, err := newExporter(discovery.NewStaticDiscovery(discovery.Instance{Addr: master.Addr()}),
+		Options{Namespace: "pika", Collectors: []string{CollectorInfo, CollectorReplicationLag}})
+	assert.NoError(err)
+	defer e.Close()
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		e.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		assert.Equal(200, w.Code)
+		assert.Contains(w.Body.String(), `pika_up{addr="`+master.Addr()+`",alias=""} 1`)
+	}
+	// the error is cached, CONFIG GET is not sent every scrape
+	assert.Equal(int32(1), atomic.LoadInt32(&configGets))
+
+	// and retried after binlogFileSizeRetryInterval
+	k := futureKey{addr: master.Addr()}
+	e.binlogMutex.Lock()
+	fileSize := e.binlogFileSizes[k]
+	fileSize.retryTime = time.Now()
+	e.binlogFileSizes[k] = fileSize
+	e.binlogMutex.Unlock()
+	e.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(int32(2), atomic.LoadInt32(&configGets))
+}
@@ -0,0 +1,208 @@
+package exporter
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pourer/pika_exporter/discovery"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// scrapeTargetIdleTimeout is how long the state of a target is kept after its last scrape.
+	scrapeTargetIdleTimeout = 10 * time.Minute
+	// defaultMaxScrapeTargets is the maximum number of the targets kept, the target not scraped for the longest
+	// time is closed for a new one.
+	defaultMaxScrapeTargets = 1024
+)
+
+var errTooManyScrapeTargets = errors.New("too many targets being scraped")
+
+// scrapeTarget is the exporter of a target kept across requests, so that the connection pool and the state
+// of the collectors, such as the last slowlog entry seen, are not thrown away by every request.
+type scrapeTarget struct {
+	e          *exporter
+	instance   discovery.Instance
+	active     int
+	lastScrape time.Time
+}
+
+type scrapeHandler struct {
+	credentials discovery.Discovery
+	anyTarget   bool
+	opt         Options
+	maxTargets  int
+	mutex       sync.Mutex
+	targets     map[string]*scrapeTarget
+}
+
+// NewScrapeHandler returns the handler of the multi-target pattern: /scrape?target=<addr>&alias=<alias>.
+// Each target is scraped by its own collector, and the password of the target is looked up by addr
+// in credentials, so it never appears in the query string. The targets not in credentials are refused
+// unless anyTarget, otherwise anyone could make the exporter connect to any address.
+func NewScrapeHandler(credentials discovery.Discovery, anyTarget bool, opt Options) (*scrapeHandler, error) {
+	// the target is scraped on every request, there is no background scrape filling the snapshot.
+	opt.ScrapeInterval = 0
+	// validates the options once, instead of on every request.
+	e, err := newExporter(discovery.NewStaticDiscovery(), opt)
+	if err != nil {
+		return nil, err
+	}
+	e.Close()
+
+	return &scrapeHandler{
+		credentials: credentials,
+		anyTarget:   anyTarget,
+		opt:         opt,
+		maxTargets:  defaultMaxScrapeTargets,
+		targets:     make(map[string]*scrapeTarget),
+	}, nil
+}
+
+func (h *scrapeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "'target' parameter must be specified", http.StatusBadRequest)
+		return
+	}
+
+	instance := discovery.Instance{
+		Addr:  target,
+		Alias: r.URL.Query().Get("alias"),
+	}
+	if credential, ok := h.lookupCredential(target); ok {
+		instance.Password = credential.Password
+		instance.Labels = credential.Labels
+		if instance.Alias == "" {
+			instance.Alias = credential.Alias
+		}
+	} else if !h.anyTarget {
+		log.Warnf("scrapeHandler::ServeHTTP unknown target refused. target:%s remote:%s", target, r.RemoteAddr)
+		http.Error(w, "unknown target "+target, http.StatusForbidden)
+		return
+	}
+
+	t, err := h.acquire(instance)
+	if err == errTooManyScrapeTargets {
+		log.Warnf("scrapeHandler::ServeHTTP target refused. target:%s err:%s", target, err.Error())
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		log.Errorf("scrapeHandler::ServeHTTP new exporter failed. target:%s err:%s", target, err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer h.release(t)
+
+	t.e.Handler().ServeHTTP(w, r)
+}
+
+// acquire returns the target of the instance, which is recreated if the alias, password or labels are changed,
+// and closes the targets not scraped for scrapeTargetIdleTimeout. The targets are kept by addr only, the alias
+// given by the query string doesn't make another target.
+func (h *scrapeHandler) acquire(instance discovery.Instance) (*scrapeTarget, error) {
+	k := instance.Addr
+	var closed []*exporter
+	defer func() {
+		for _, e := range closed {
+			e.Close()
+		}
+	}()
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	now := time.Now()
+	for key, t := range h.targets {
+		if key != k && t.active == 0 && now.Sub(t.lastScrape) > scrapeTargetIdleTimeout {
+			closed = append(closed, t.e)
+			delete(h.targets, key)
+		}
+	}
+
+	t, ok := h.targets[k]
+	if ok && t.active == 0 && !sameInstance(t.instance, instance) {
+		closed = append(closed, t.e)
+		ok = false
+	}
+	if !ok {
+		delete(h.targets, k)
+		if len(h.targets) >= h.maxTargets {
+			oldest, ok := h.oldestIdleTarget()
+			if !ok {
+				return nil, errTooManyScrapeTargets
+			}
+			closed = append(closed, h.targets[oldest].e)
+			delete(h.targets, oldest)
+		}
+
+		e, err := newExporter(discovery.NewStaticDiscovery(instance), h.opt)
+		if err != nil {
+			return nil, err
+		}
+		t = &scrapeTarget{e: e, instance: instance}
+		h.targets[k] = t
+	}
+	t.active++
+	t.lastScrape = now
+	return t, nil
+}
+
+// oldestIdleTarget returns the target not being scraped, and not scraped for the longest time.
+func (h *scrapeHandler) oldestIdleTarget() (string, bool) {
+	var (
+		oldest string
+		found  bool
+	)
+	for k, t := range h.targets {
+		if t.active == 0 && (!found || t.lastScrape.Before(h.targets[oldest].lastScrape)) {
+			oldest, found = k, true
+		}
+	}
+	return oldest, found
+}
+
+func (h *scrapeHandler) release(t *scrapeTarget) {
+	h.mutex.Lock()
+	t.active--
+	h.mutex.Unlock()
+}
+
+// Close closes the exporters of all the targets.
+func (h *scrapeHandler) Close() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for k, t := range h.targets {
+		t.e.Close()
+		delete(h.targets, k)
+	}
+	return nil
+}
+
+func (h *scrapeHandler) lookupCredential(addr string) (discovery.Instance, bool) {
+	if h.credentials == nil {
+		return discovery.Instance{}, false
+	}
+
+	for _, instance := range h.credentials.GetInstances() {
+		if instance.Addr == addr {
+			return instance, true
+		}
+	}
+	return discovery.Instance{}, false
+}
+
+func sameInstance(a, b discovery.Instance) bool {
+	if a.Alias != b.Alias || a.Password != b.Password || len(a.Labels) != len(b.Labels) {
+		return false
+	}
+	for name, value := range a.Labels {
+		if v, ok := b.Labels[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
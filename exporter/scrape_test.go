@@ -0,0 +1,140 @@
+package exporter
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pourer/pika_exporter/discovery"
+	"github.com/pourer/pika_exporter/internal/respserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrapeHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	// a listener closed immediately, so that the target refuses connections
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(err)
+	target := l.Addr().String()
+	l.Close()
+
+	credentials := discovery.NewStaticDiscovery(discovery.Instance{Addr: target, Password: "pwd", Alias: "from-file"})
+	h, err := NewScrapeHandler(credentials, false, Options{Namespace: "pika", ScanCount: 100})
+	assert.NoError(err)
+	defer h.Close()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/scrape", nil))
+	assert.Equal(http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/scrape?target="+target, nil))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Body.String(), `pika_up{addr="`+target+`",alias="from-file"} 0`)
+	assert.NotContains(w.Body.String(), "pwd")
+
+	// the targets not in the credentials are refused, unless any target is allowed
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/scrape?target=127.0.0.1:1", nil))
+	assert.Equal(http.StatusForbidden, w.Code)
+
+	h2, err := NewScrapeHandler(credentials, true, Options{Namespace: "pika", ScanCount: 100})
+	assert.NoError(err)
+	defer h2.Close()
+	w = httptest.NewRecorder()
+	h2.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/scrape?target=127.0.0.1:1", nil))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Body.String(), `pika_up{addr="127.0.0.1:1",alias=""} 0`)
+
+	// the scrape interval of the telemetry path doesn't apply to the scrape path
+	h3, err := NewScrapeHandler(credentials, false, Options{Namespace: "pika", ScanCount: 100, ScrapeInterval: time.Minute})
+	assert.NoError(err)
+	defer h3.Close()
+	w = httptest.NewRecorder()
+	h3.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/scrape?target="+target, nil))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Body.String(), `pika_up{addr="`+target+`",alias="from-file"} 0`)
+}
+
+func TestScrapeHandler_TargetState(t *testing.T) {
+	assert := assert.New(t)
+
+	var clientLists int32
+	s := respserver.New(t, func(args []string) interface{} {
+		if strings.ToUpper(args[0]) == "CLIENT" {
+			atomic.AddInt32(&clientLists, 1)
+			return "addr=10.0.0.1:50001 fd=10 name= age=100 idle=0 db=0 cmd=get"
+		}
+		return respserver.Status("OK")
+	})
+	defer s.Close()
+
+	credentials := discovery.NewStaticDiscovery(discovery.Instance{Addr: s.Addr()})
+	h, err := NewScrapeHandler(credentials, false, Options{Namespace: "pika", Collectors: []string{CollectorClients}})
+	assert.NoError(err)
+	defer h.Close()
+
+	// the connection pool and CLIENT LIST of the clients collector are kept across the requests
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/scrape?target="+s.Addr(), nil))
+		assert.Equal(http.StatusOK, w.Code)
+		assert.Contains(w.Body.String(), `pika_exporter_pool_dial_count{addr="`+s.Addr()+`",alias=""} 1`)
+		assert.Contains(w.Body.String(), `pika_client_connections{addr="`+s.Addr()+`",alias="",ip="10.0.0.1"} 1`)
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&clientLists))
+}
+
+func TestScrapeHandler_TargetLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := func(args []string) interface{} {
+		return respserver.Status("OK")
+	}
+	s1, s2, s3 := respserver.New(t, handler), respserver.New(t, handler), respserver.New(t, handler)
+	defer s1.Close()
+	defer s2.Close()
+	defer s3.Close()
+
+	credentials := discovery.NewStaticDiscovery(discovery.Instance{Addr: s1.Addr()},
+		discovery.Instance{Addr: s2.Addr()}, discovery.Instance{Addr: s3.Addr()})
+	h, err := NewScrapeHandler(credentials, false, Options{Namespace: "pika", Collectors: []string{CollectorInfo}})
+	assert.NoError(err)
+	defer h.Close()
+	h.maxTargets = 2
+
+	scrape := func(query string) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/scrape?"+query, nil))
+		assert.Equal(http.StatusOK, w.Code)
+	}
+
+	// the aliases of the query string don't make more targets
+	for _, alias := range []string{"a", "b", "c"} {
+		scrape("target=" + s1.Addr() + "&alias=" + alias)
+	}
+	assert.Len(h.targets, 1)
+	assert.Equal("c", h.targets[s1.Addr()].instance.Alias)
+
+	// the target not scraped for the longest time is closed for a new one
+	scrape("target=" + s2.Addr())
+	scrape("target=" + s3.Addr())
+	assert.Len(h.targets, 2)
+	assert.NotContains(h.targets, s1.Addr())
+
+	// no target is closed while being scraped
+	for _, t := range h.targets {
+		t.active++
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/scrape?target="+s1.Addr(), nil))
+	assert.Equal(http.StatusServiceUnavailable, w.Code)
+	for _, t := range h.targets {
+		t.active--
+	}
+}
@@ -0,0 +1,153 @@
+package exporter
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// slowlogBuckets are the buckets of the slowlog durations in seconds, 1ms ~ 10s.
+var slowlogBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// slowlogHistogram is the histogram of the slowlog durations of a command.
+type slowlogHistogram struct {
+	count   uint64
+	sum     float64
+	buckets map[float64]uint64
+}
+
+func (h *slowlogHistogram) observe(seconds float64) {
+	h.count++
+	h.sum += seconds
+	for _, upperBound := range slowlogBuckets {
+		if seconds <= upperBound {
+			h.buckets[upperBound]++
+		}
+	}
+}
+
+// slowlogBatch is the maximum number of the entries of SLOWLOG GET of a scrape, the new entries beyond it
+// since the last scrape are not counted.
+const slowlogBatch = 128
+
+// slowlogState is the slowlog entries of an instance seen by the scrapes, the entries are counted
+// only once by remembering the id of the last seen one.
+type slowlogState struct {
+	lastID     int64
+	lastUptime int64
+	seen       bool
+	commands   map[string]*slowlogHistogram
+}
+
+func newSlowlogState() *slowlogState {
+	return &slowlogState{lastID: -1, commands: make(map[string]*slowlogHistogram)}
+}
+
+// fetchCount is the count of SLOWLOG GET, only the latest entry is needed to remember its id when the instance
+// is seen for the first time.
+func (s *slowlogState) fetchCount(length int) int {
+	if !s.seen && length > 0 {
+		return 1
+	}
+	if length > slowlogBatch {
+		return slowlogBatch
+	}
+	return length
+}
+
+// restarted reports whether pika has restarted since the last scrape, so that the ids start from 0 again.
+// The latest id may be newer than the last seen one if pika has logged more entries since the restart, so the
+// uptime shorter than the last time is checked as well. The slowlog getting shorter is not a restart, since
+// lowering slowlog-max-len or SLOWLOG RESET drops the entries but keeps the ids.
+func (s *slowlogState) restarted(uptime int64, entries []slowlogEntry) bool {
+	if uptime < s.lastUptime {
+		return true
+	}
+	return len(entries) > 0 && entries[0].id < s.lastID
+}
+
+// add counts the entries newer than the last seen one, the entries are the latest first. The entries seen
+// for the first time of the instance are not counted, since they are logged before the exporter started.
+func (s *slowlogState) add(uptime int64, entries []slowlogEntry) {
+	if !s.seen {
+		if len(entries) > 0 {
+			s.lastID = entries[0].id
+		}
+		s.lastUptime, s.seen = uptime, true
+		return
+	}
+	if s.restarted(uptime, entries) {
+		s.lastID = -1
+	}
+
+	for _, entry := range entries {
+		if entry.id <= s.lastID {
+			break
+		}
+
+		h, ok := s.commands[entry.command]
+		if !ok {
+			h = &slowlogHistogram{buckets: make(map[float64]uint64, len(slowlogBuckets))}
+			s.commands[entry.command] = h
+		}
+		h.observe(entry.duration.Seconds())
+	}
+	if len(entries) > 0 {
+		s.lastID = entries[0].id
+	}
+	s.lastUptime = uptime
+}
+
+// collectSlowlog counts the new entries of SLOWLOG GET since the last scrape of the instance, the entries
+// are labeled by the command name only, never by the arguments.
+func (e *exporter) collectSlowlog(c *client, im *instanceMetrics) ([]prometheus.Metric, error) {
+	uptime, err := c.Uptime()
+	if err != nil {
+		return nil, err
+	}
+	length, err := c.SlowlogLen()
+	if err != nil {
+		return nil, err
+	}
+
+	k := futureKey{addr: c.Addr(), alias: c.Alias()}
+	e.slowlogMutex.Lock()
+	s, ok := e.slowlogs[k]
+	if !ok {
+		s = newSlowlogState()
+		e.slowlogs[k] = s
+	}
+	count := s.fetchCount(length)
+	e.slowlogMutex.Unlock()
+
+	var entries []slowlogEntry
+	if count > 0 {
+		if entries, err = c.SlowlogGet(count); err != nil {
+			return nil, err
+		}
+	}
+
+	e.slowlogMutex.Lock()
+	defer e.slowlogMutex.Unlock()
+
+	s.add(uptime, entries)
+
+	commands := make([]string, 0, len(s.commands))
+	for command := range s.commands {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+
+	promMetrics := []prometheus.Metric{
+		prometheus.MustNewConstMetric(im.slowlogLength, prometheus.GaugeValue, float64(length),
+			im.labelValues(k.addr, k.alias)...),
+	}
+	for _, command := range commands {
+		h := s.commands[command]
+		labelValues := im.labelValues(k.addr, k.alias, command)
+		promMetrics = append(promMetrics,
+			prometheus.MustNewConstMetric(im.slowlogCount, prometheus.CounterValue, float64(h.count), labelValues...),
+			prometheus.MustNewConstHistogram(im.slowlogDuration, h.count, h.sum, h.buckets, labelValues...))
+	}
+	return promMetrics, nil
+}
@@ -0,0 +1,162 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pourer/pika_exporter/discovery"
+	"github.com/pourer/pika_exporter/internal/respserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func slowlogReply(id, usec int, args ...string) []interface{} {
+	return []interface{}{id, 1679364000, usec, args}
+}
+
+func TestExporter_Slowlog(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		mu         sync.Mutex
+		entries    []interface{}
+		fetchCount int
+		uptime     = 1000
+	)
+	s := respserver.New(t, func(args []string) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch strings.ToUpper(args[0]) {
+		case "INFO":
+			return "# Server\r\nuptime_in_seconds:" + strconv.Itoa(uptime) + "\r\n"
+		case "SLOWLOG":
+		default:
+			return respserver.Status("OK")
+		}
+		switch strings.ToUpper(args[1]) {
+		case "LEN":
+			return len(entries)
+		case "GET":
+			fetchCount, _ = strconv.Atoi(args[2])
+			if fetchCount < len(entries) {
+				return entries[:fetchCount]
+			}
+			return entries
+		}
+		return respserver.Status("OK")
+	})
+	defer s.Close()
+
+	e, err := newExporter(discovery.NewStaticDiscovery(discovery.Instance{Addr: s.Addr()}),
+		Options{Namespace: "pika", Collectors: []string{CollectorSlowlog}})
+	assert.NoError(err)
+	defer e.Close()
+
+	scrape := func(newEntries ...[]interface{}) string {
+		mu.Lock()
+		for _, entry := range newEntries {
+			entries = append([]interface{}{entry}, entries...)
+		}
+		mu.Unlock()
+
+		w := httptest.NewRecorder()
+		e.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		assert.Equal(200, w.Code)
+		return w.Body.String()
+	}
+	labels := func(command string) string {
+		return `{addr="` + s.Addr() + `",alias="",command="` + command + `"}`
+	}
+
+	body := scrape()
+	assert.Contains(body, `pika_slowlog_length{addr="`+s.Addr()+`",alias=""} 0`)
+	assert.NotContains(body, "pika_slowlog_count{")
+
+	body = scrape(slowlogReply(0, 20000, "GET", "secret"), slowlogReply(1, 5000, "SET", "k", "secret"))
+	assert.Contains(body, `pika_slowlog_length{addr="`+s.Addr()+`",alias=""} 2`)
+	assert.Contains(body, "pika_slowlog_count"+labels("get")+" 1")
+	assert.Contains(body, "pika_slowlog_count"+labels("set")+" 1")
+	assert.Contains(body, `pika_slowlog_duration_seconds_bucket{addr="`+s.Addr()+`",alias="",command="get",le="0.025"} 1`)
+	assert.NotContains(body, "secret")
+
+	// only the entries newer than the last seen one are counted
+	body = scrape(slowlogReply(2, 200000, "GET", "k"))
+	assert.Contains(body, "pika_slowlog_count"+labels("get")+" 2")
+	assert.Contains(body, "pika_slowlog_count"+labels("set")+" 1")
+	assert.Contains(body, `pika_slowlog_duration_seconds_bucket{addr="`+s.Addr()+`",alias="",command="get",le="0.1"} 1`)
+	assert.Contains(body, "pika_slowlog_duration_seconds_sum"+labels("get")+" 0.22")
+
+	body = scrape()
+	assert.Contains(body, "pika_slowlog_count"+labels("get")+" 2")
+
+	// the ids start from 0 again after pika restarts
+	mu.Lock()
+	entries = nil
+	mu.Unlock()
+	body = scrape(slowlogReply(0, 30000, "HGETALL", "k"))
+	assert.Contains(body, "pika_slowlog_count"+labels("hgetall")+" 1")
+	assert.Contains(body, "pika_slowlog_count"+labels("get")+" 2")
+
+	// the restart is detected by the uptime, though the latest id is newer than the last seen one
+	mu.Lock()
+	entries, uptime = nil, 10
+	mu.Unlock()
+	var restarted [][]interface{}
+	for id := 0; id < 5; id++ {
+		restarted = append(restarted, slowlogReply(id, 30000, "HGETALL", "k"))
+	}
+	body = scrape(restarted...)
+	assert.Contains(body, "pika_slowlog_count"+labels("hgetall")+" 6")
+
+	// at most slowlogBatch entries are fetched
+	var many [][]interface{}
+	for id := 5; id < 5+slowlogBatch*2; id++ {
+		many = append(many, slowlogReply(id, 1000, "SCAN", "0"))
+	}
+	body = scrape(many...)
+	assert.Equal(slowlogBatch, fetchCount)
+	assert.Contains(body, "pika_slowlog_count"+labels("scan")+" "+strconv.Itoa(slowlogBatch))
+
+	// lowering slowlog-max-len drops the oldest entries but keeps the ids, the entries are not counted again
+	next := 5 + slowlogBatch*2
+	mu.Lock()
+	entries = entries[:4]
+	mu.Unlock()
+	body = scrape()
+	assert.Contains(body, `pika_slowlog_length{addr="`+s.Addr()+`",alias=""} 4`)
+	assert.Contains(body, "pika_slowlog_count"+labels("scan")+" "+strconv.Itoa(slowlogBatch))
+	body = scrape(slowlogReply(next, 1000, "SCAN", "0"))
+	assert.Contains(body, "pika_slowlog_count"+labels("scan")+" "+strconv.Itoa(slowlogBatch+1))
+
+	// nor after SLOWLOG RESET
+	mu.Lock()
+	entries = nil
+	mu.Unlock()
+	body = scrape(slowlogReply(next+1, 1000, "SCAN", "0"))
+	assert.Contains(body, "pika_slowlog_count"+labels("scan")+" "+strconv.Itoa(slowlogBatch+2))
+}
+
+func TestSlowlogState(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newSlowlogState()
+	assert.Equal(1, s.fetchCount(100))
+	// the entries logged before the first scrape are not counted
+	s.add(100, []slowlogEntry{{id: 99, command: "get"}})
+	assert.Empty(s.commands)
+	assert.Equal(0, s.fetchCount(0))
+	assert.Equal(slowlogBatch, s.fetchCount(1000))
+
+	s.add(100, []slowlogEntry{{id: 100, command: "set"}, {id: 99, command: "get"}})
+	assert.Equal(uint64(1), s.commands["set"].count)
+	assert.NotContains(s.commands, "get")
+
+	// the restart is detected by the uptime and the ids, not by the slowlog getting shorter
+	assert.False(s.restarted(100, []slowlogEntry{{id: 100}}))
+	assert.False(s.restarted(100, nil))
+	assert.True(s.restarted(10, []slowlogEntry{{id: 119}}))
+	assert.True(s.restarted(100, []slowlogEntry{{id: 50}}))
+}
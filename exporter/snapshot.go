@@ -0,0 +1,106 @@
+package exporter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+)
+
+// snapshot is the metrics of a completed scrape of all the instances, it's never modified after created,
+// so that it can be served to any number of concurrent requests.
+type snapshot struct {
+	metrics []prometheus.Metric
+	time    time.Time
+}
+
+// frozenMetric is a metric whose value is written out at the time of the snapshot.
+type frozenMetric struct {
+	desc   *prometheus.Desc
+	metric *dto.Metric
+}
+
+func (m *frozenMetric) Desc() *prometheus.Desc {
+	return m.desc
+}
+
+func (m *frozenMetric) Write(out *dto.Metric) error {
+	out.Label = m.metric.Label
+	out.Gauge = m.metric.Gauge
+	out.Counter = m.metric.Counter
+	out.Summary = m.metric.Summary
+	out.Untyped = m.metric.Untyped
+	out.Histogram = m.metric.Histogram
+	out.TimestampMs = m.metric.TimestampMs
+	return nil
+}
+
+func freezeMetric(m prometheus.Metric) (prometheus.Metric, error) {
+	metric := new(dto.Metric)
+	if err := m.Write(metric); err != nil {
+		return nil, err
+	}
+	return &frozenMetric{desc: m.Desc(), metric: metric}, nil
+}
+
+// scrapeLoop scrapes all the instances every interval in the background, and replaces the snapshot
+// served by Collect, so that the requests of /metrics never hit pika directly. Each scrape is bounded
+// by the interval, the instances not finished by then are left out of the snapshot.
+func (e *exporter) scrapeLoop(interval time.Duration) {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		e.takeSnapshot(ctx)
+		cancel()
+
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *exporter) takeSnapshot(ctx context.Context) {
+	ch := make(chan prometheus.Metric)
+	s := &snapshot{}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for m := range ch {
+			frozen, err := freezeMetric(m)
+			if err != nil {
+				log.Warnf("exporter::takeSnapshot write metric failed. metric:%s err:%s", m.Desc(), err.Error())
+				continue
+			}
+			s.metrics = append(s.metrics, frozen)
+		}
+	}()
+
+	e.collect(ctx, ch)
+	close(ch)
+	wg.Wait()
+
+	s.time = time.Now()
+	e.snapshot.Store(s)
+}
+
+func (e *exporter) collectSnapshot(ch chan<- prometheus.Metric) {
+	s, ok := e.snapshot.Load().(*snapshot)
+	if !ok {
+		return
+	}
+
+	for _, m := range s.metrics {
+		ch <- m
+	}
+	ch <- prometheus.MustNewConstMetric(e.snapshotAge, prometheus.GaugeValue, time.Since(s.time).Seconds())
+}
@@ -0,0 +1,72 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pourer/pika_exporter/discovery"
+	"github.com/pourer/pika_exporter/internal/respserver"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExporter_Snapshot(t *testing.T) {
+	assert := assert.New(t)
+
+	e, err := NewPikaExporter(&fakeDiscovery{}, Options{Namespace: "pika", StatsClockHour: -1, ScrapeInterval: time.Hour})
+	assert.NoError(err)
+	defer e.Close()
+
+	assert.Eventually(func() bool {
+		return e.snapshot.Load() != nil
+	}, time.Second, 10*time.Millisecond)
+
+	registry := prometheus.NewRegistry()
+	assert.NoError(registry.Register(e))
+
+	// every request is served from the same snapshot, without collecting again
+	for i := 0; i < 2; i++ {
+		mfs, err := registry.Gather()
+		assert.NoError(err)
+
+		values := make(map[string]*dto.Metric)
+		for _, mf := range mfs {
+			values[mf.GetName()] = mf.GetMetric()[0]
+		}
+		if assert.Contains(values, "pika_exporter_collect_count") {
+			assert.Equal(1.0, values["pika_exporter_collect_count"].GetCounter().GetValue())
+		}
+		assert.Contains(values, "pika_exporter_snapshot_age_seconds")
+	}
+}
+
+func TestExporter_SnapshotNotWaiting(t *testing.T) {
+	assert := assert.New(t)
+
+	// the node hangs on INFO until the end of the test.
+	release := make(chan struct{})
+	s := respserver.New(t, func(args []string) interface{} {
+		if strings.ToUpper(args[0]) == "INFO" {
+			<-release
+		}
+		return respserver.Status("OK")
+	})
+	defer s.Close()
+
+	e, err := NewPikaExporter(discovery.NewStaticDiscovery(discovery.Instance{Addr: s.Addr()}),
+		Options{Namespace: "pika", StatsClockHour: -1, ScrapeInterval: time.Hour, Collectors: []string{CollectorInfo}})
+	assert.NoError(err)
+	defer e.Close()
+	defer close(release)
+	time.Sleep(100 * time.Millisecond)
+
+	// the request is served from the snapshot while the background scrape is waiting for the node.
+	startTime := time.Now()
+	w := httptest.NewRecorder()
+	e.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(200, w.Code)
+	assert.True(time.Since(startTime) < time.Second, "elapsed:%s", time.Since(startTime))
+}
@@ -29,4 +29,12 @@ var InfoCases = []struct {
 
 	{"v3.3.5_master", V335MasterInfo},
 	{"v3.3.5_slave", V335SlaveInfo},
+
+	{"v3.4.2_master", V342MasterInfo},
+	{"v3.4.2_slave", V342SlaveInfo},
+
+	{"v3.5.0_master", V350MasterInfo},
+	{"v3.5.0_slave", V350SlaveInfo},
+
+	{"v4.0.0_master", V400MasterInfo},
 }
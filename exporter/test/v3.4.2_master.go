@@ -0,0 +1,79 @@
+package test
+
+var V342MasterInfo = `# Server
+pika_version:3.4.2
+pika_git_sha:bd30511bf82038c2c6531b3d84872c9825fe836a
+pika_build_compile_date: Jun 29 2021
+os:Linux 3.10.0-1160.el7.x86_64 x86_64
+arch_bits:64
+process_id:25314
+tcp_port:9221
+thread_num:24
+sync_thread_num:6
+uptime_in_seconds:1728063
+uptime_in_days:21
+config_file:/data/pika/conf/pika.conf
+server_id:1
+
+# Data
+db_size:5327891712
+db_size_human:5081M
+log_size:8053063680
+log_size_human:7680M
+compression:snappy
+used_memory:1201672192
+used_memory_human:1146M
+db_memtable_usage:1073741824
+db_tablereader_usage:127930368
+db_fatal:0
+db_fatal_msg:NULL
+
+# Clients
+connected_clients:38
+
+# Stats
+total_connections_received:62013
+instantaneous_ops_per_sec:5312
+total_commands_processed:9133847204
+is_bgsaving:No
+is_scaning_keyspace:No
+is_compact:No
+compact_cron:03-04/30
+compact_interval:
+
+# Command_Exec_Count
+GET:5033847201
+SET:3900000000
+HGETALL:200000000
+INFO:120003
+PING:3
+SLAVEOF:1
+
+# CPU
+used_cpu_sys:86340.21
+used_cpu_user:120311.45
+used_cpu_sys_children:0.00
+used_cpu_user_children:0.00
+
+# Replication(MASTER)
+role:master
+connected_slaves:1
+slave0:ip=10.20.30.41,port=9221,conn_fd=104,lag=(db0:0)(db1:1024)
+db0 binlog_offset=75 60416,safety_purge=write2file65
+db0 consensus last_log=b_offset: filenum: 75 offset: 60416, l_offset: term: 3 index: 8843012
+db1 binlog_offset=12 1048576,safety_purge=none
+db1 consensus last_log=b_offset: filenum: 12 offset: 1048576, l_offset: term: 3 index: 1290341
+
+# Keyspace
+# Time:2021-07-20 03:00:01
+# Duration: 32s
+db0 Strings_keys=15003922, expires=1203, invalid_keys=0
+db0 Hashes_keys=3123, expires=0, invalid_keys=0
+db0 Lists_keys=0, expires=0, invalid_keys=0
+db0 Zsets_keys=0, expires=0, invalid_keys=0
+db0 Sets_keys=0, expires=0, invalid_keys=0
+db1 Strings_keys=200, expires=0, invalid_keys=0
+db1 Hashes_keys=0, expires=0, invalid_keys=0
+db1 Lists_keys=0, expires=0, invalid_keys=0
+db1 Zsets_keys=0, expires=0, invalid_keys=0
+db1 Sets_keys=0, expires=0, invalid_keys=0`
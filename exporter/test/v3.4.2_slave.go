@@ -0,0 +1,78 @@
+package test
+
+var V342SlaveInfo = `# Server
+pika_version:3.4.2
+pika_git_sha:bd30511bf82038c2c6531b3d84872c9825fe836a
+pika_build_compile_date: Jun 29 2021
+os:Linux 3.10.0-1160.el7.x86_64 x86_64
+arch_bits:64
+process_id:11023
+tcp_port:9221
+thread_num:24
+sync_thread_num:6
+uptime_in_seconds:1727950
+uptime_in_days:21
+config_file:/data/pika/conf/pika.conf
+server_id:2
+
+# Data
+db_size:5327881472
+db_size_human:5081M
+log_size:8053063680
+log_size_human:7680M
+compression:snappy
+used_memory:1190234112
+used_memory_human:1135M
+db_memtable_usage:1062303744
+db_tablereader_usage:127930368
+db_fatal:0
+db_fatal_msg:NULL
+
+# Clients
+connected_clients:4
+
+# Stats
+total_connections_received:3011
+instantaneous_ops_per_sec:12
+total_commands_processed:2091883
+is_bgsaving:No
+is_scaning_keyspace:No
+is_compact:No
+compact_cron:03-04/30
+compact_interval:
+
+# Command_Exec_Count
+INFO:120001
+PING:2
+SLAVEOF:1
+
+# CPU
+used_cpu_sys:20340.11
+used_cpu_user:30311.21
+used_cpu_sys_children:0.00
+used_cpu_user_children:0.00
+
+# Replication(SLAVE)
+role:slave
+master_host:10.20.30.40
+master_port:9221
+master_link_status:up
+slave_priority:100
+slave_read_only:1
+db_repl_state:(db1:kTryDBSync)
+db0 binlog_offset=75 60416,safety_purge=none
+db1 binlog_offset=11 2097152,safety_purge=none
+
+# Keyspace
+# Time:2021-07-20 03:00:02
+# Duration: In Processing
+db0 Strings_keys=15003922, expires=1203, invalid_keys=0
+db0 Hashes_keys=3123, expires=0, invalid_keys=0
+db0 Lists_keys=0, expires=0, invalid_keys=0
+db0 Zsets_keys=0, expires=0, invalid_keys=0
+db0 Sets_keys=0, expires=0, invalid_keys=0
+db1 Strings_keys=0, expires=0, invalid_keys=0
+db1 Hashes_keys=0, expires=0, invalid_keys=0
+db1 Lists_keys=0, expires=0, invalid_keys=0
+db1 Zsets_keys=0, expires=0, invalid_keys=0
+db1 Sets_keys=0, expires=0, invalid_keys=0`
@@ -0,0 +1,204 @@
+package test
+
+var V350MasterInfo = `# Server
+pika_version:3.5.0
+pika_git_sha:6a3ba2f7c2cd8e3e0ca87e5b8b9bf0ff4ca0f0ac
+pika_build_compile_date: Mar 14 2023
+os:Linux 5.4.119-1-tlinux4-0009.1 x86_64
+arch_bits:64
+process_id:31421
+tcp_port:9221
+thread_num:8
+sync_thread_num:6
+uptime_in_seconds:604821
+uptime_in_days:7
+config_file:/data/pika/conf/pika.conf
+server_id:1
+
+# Data
+db_size:214748364
+db_size_human:204M
+log_size:1073741824
+log_size_human:1024M
+compression:snappy
+used_memory:134217728
+used_memory_human:128M
+db_memtable_usage:100663296
+db_tablereader_usage:33554432
+db_fatal:0
+db_fatal_msg:NULL
+
+# Clients
+connected_clients:12
+
+# Stats
+total_connections_received:9120
+instantaneous_ops_per_sec:1203
+total_commands_processed:731223901
+keyspace_hits:510332011
+keyspace_misses:20339102
+is_bgsaving:No
+is_scaning_keyspace:No
+is_compact:No
+compact_cron:
+compact_interval:
+
+# Command_Exec_Count
+GET:530671113
+SET:200500000
+INFO:52311
+PING:1
+
+# CPU
+used_cpu_sys:3120.02
+used_cpu_user:4801.91
+used_cpu_sys_children:0.00
+used_cpu_user_children:0.00
+
+# Replication(MASTER)
+role:master
+ReplicationID:94e8feeaf9036a77c59ad2f091f96c0b6c6cb5a3e6e9f2ee5b
+connected_slaves:1
+slave0:ip=10.20.30.52,port=9221,conn_fd=91,lag=(db0:0)
+db0 binlog_offset=9 4096,safety_purge=write2file0
+
+# Keyspace
+# Time:2023-03-21 10:00:00
+# Duration: 2s
+db0 Strings_keys=1201133, expires=0, invalid_keys=0
+db0 Hashes_keys=0, expires=0, invalid_keys=0
+db0 Lists_keys=0, expires=0, invalid_keys=0
+db0 Zsets_keys=0, expires=0, invalid_keys=0
+db0 Sets_keys=0, expires=0, invalid_keys=0
+
+# RocksDB
+#strings_RocksDB
+strings_num_immutable_mem_table:1
+strings_num_immutable_mem_table_flushed:0
+strings_mem_table_flush_pending:1
+strings_num_running_flushes:1
+strings_compaction_pending:1
+strings_num_running_compactions:2
+strings_background_errors:0
+strings_cur_size_active_mem_table:52428800
+strings_cur_size_all_mem_tables:119537664
+strings_size_all_mem_tables:119537664
+strings_estimate_num_keys:1201133
+strings_estimate_table_readers_mem:33554432
+strings_num_snapshots:0
+strings_num_live_versions:3
+strings_current_super_version_number:1093
+strings_estimate_live_data_size:201326592
+strings_total_sst_files_size:214748364
+strings_live_sst_files_size:214748364
+strings_estimate_pending_compaction_bytes:73400320
+strings_block_cache_capacity:8388608
+strings_block_cache_usage:7340032
+strings_block_cache_pinned_usage:1048576
+strings_num_files_at_level0:9
+strings_num_files_at_level1:4
+strings_num_files_at_level2:31
+#hashes_RocksDB
+hashes_num_immutable_mem_table:0
+hashes_num_immutable_mem_table_flushed:0
+hashes_mem_table_flush_pending:0
+hashes_num_running_flushes:0
+hashes_compaction_pending:0
+hashes_num_running_compactions:0
+hashes_background_errors:0
+hashes_cur_size_active_mem_table:0
+hashes_cur_size_all_mem_tables:0
+hashes_size_all_mem_tables:0
+hashes_estimate_num_keys:0
+hashes_estimate_table_readers_mem:0
+hashes_num_snapshots:0
+hashes_num_live_versions:1
+hashes_current_super_version_number:4
+hashes_estimate_live_data_size:0
+hashes_total_sst_files_size:0
+hashes_live_sst_files_size:0
+hashes_estimate_pending_compaction_bytes:0
+hashes_block_cache_capacity:8388608
+hashes_block_cache_usage:0
+hashes_block_cache_pinned_usage:0
+hashes_num_files_at_level0:0
+hashes_num_files_at_level1:0
+hashes_num_files_at_level2:0
+#lists_RocksDB
+lists_num_immutable_mem_table:0
+lists_num_immutable_mem_table_flushed:0
+lists_mem_table_flush_pending:0
+lists_num_running_flushes:0
+lists_compaction_pending:0
+lists_num_running_compactions:0
+lists_background_errors:0
+lists_cur_size_active_mem_table:0
+lists_cur_size_all_mem_tables:0
+lists_size_all_mem_tables:0
+lists_estimate_num_keys:0
+lists_estimate_table_readers_mem:0
+lists_num_snapshots:0
+lists_num_live_versions:1
+lists_current_super_version_number:4
+lists_estimate_live_data_size:0
+lists_total_sst_files_size:0
+lists_live_sst_files_size:0
+lists_estimate_pending_compaction_bytes:0
+lists_block_cache_capacity:8388608
+lists_block_cache_usage:0
+lists_block_cache_pinned_usage:0
+lists_num_files_at_level0:0
+lists_num_files_at_level1:0
+lists_num_files_at_level2:0
+#zsets_RocksDB
+zsets_num_immutable_mem_table:0
+zsets_num_immutable_mem_table_flushed:0
+zsets_mem_table_flush_pending:0
+zsets_num_running_flushes:0
+zsets_compaction_pending:0
+zsets_num_running_compactions:0
+zsets_background_errors:0
+zsets_cur_size_active_mem_table:0
+zsets_cur_size_all_mem_tables:0
+zsets_size_all_mem_tables:0
+zsets_estimate_num_keys:0
+zsets_estimate_table_readers_mem:0
+zsets_num_snapshots:0
+zsets_num_live_versions:1
+zsets_current_super_version_number:4
+zsets_estimate_live_data_size:0
+zsets_total_sst_files_size:0
+zsets_live_sst_files_size:0
+zsets_estimate_pending_compaction_bytes:0
+zsets_block_cache_capacity:8388608
+zsets_block_cache_usage:0
+zsets_block_cache_pinned_usage:0
+zsets_num_files_at_level0:0
+zsets_num_files_at_level1:0
+zsets_num_files_at_level2:0
+#sets_RocksDB
+sets_num_immutable_mem_table:0
+sets_num_immutable_mem_table_flushed:0
+sets_mem_table_flush_pending:0
+sets_num_running_flushes:0
+sets_compaction_pending:0
+sets_num_running_compactions:0
+sets_background_errors:0
+sets_cur_size_active_mem_table:0
+sets_cur_size_all_mem_tables:0
+sets_size_all_mem_tables:0
+sets_estimate_num_keys:0
+sets_estimate_table_readers_mem:0
+sets_num_snapshots:0
+sets_num_live_versions:1
+sets_current_super_version_number:4
+sets_estimate_live_data_size:0
+sets_total_sst_files_size:0
+sets_live_sst_files_size:0
+sets_estimate_pending_compaction_bytes:0
+sets_block_cache_capacity:8388608
+sets_block_cache_usage:0
+sets_block_cache_pinned_usage:0
+sets_num_files_at_level0:0
+sets_num_files_at_level1:0
+sets_num_files_at_level2:0`
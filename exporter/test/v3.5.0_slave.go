@@ -0,0 +1,75 @@
+package test
+
+var V350SlaveInfo = `# Server
+pika_version:3.5.0
+pika_git_sha:6a3ba2f7c2cd8e3e0ca87e5b8b9bf0ff4ca0f0ac
+pika_build_compile_date: Mar 14 2023
+os:Linux 5.4.119-1-tlinux4-0009.1 x86_64
+arch_bits:64
+process_id:8812
+tcp_port:9221
+thread_num:8
+sync_thread_num:6
+uptime_in_seconds:604800
+uptime_in_days:7
+config_file:/data/pika/conf/pika.conf
+server_id:2
+
+# Data
+db_size:214748364
+db_size_human:204M
+log_size:1073741824
+log_size_human:1024M
+compression:snappy
+used_memory:134217728
+used_memory_human:128M
+db_memtable_usage:100663296
+db_tablereader_usage:33554432
+db_fatal:0
+db_fatal_msg:NULL
+
+# Clients
+connected_clients:3
+
+# Stats
+total_connections_received:310
+instantaneous_ops_per_sec:2
+total_commands_processed:53001
+keyspace_hits:0
+keyspace_misses:0
+is_bgsaving:No
+is_scaning_keyspace:No
+is_compact:No
+compact_cron:
+compact_interval:
+
+# Command_Exec_Count
+INFO:52310
+SLAVEOF:1
+
+# CPU
+used_cpu_sys:1120.52
+used_cpu_user:801.33
+used_cpu_sys_children:0.00
+used_cpu_user_children:0.00
+
+# Replication(Master && SLAVE)
+role:master&&slave
+ReplicationID:94e8feeaf9036a77c59ad2f091f96c0b6c6cb5a3e6e9f2ee5b
+master_host:10.20.30.51
+master_port:9221
+master_link_status:up
+slave_priority:100
+slave_read_only:1
+connected_slaves:1
+slave0:ip=10.20.30.53,port=9221,conn_fd=77,lag=(db0:0)
+db0 binlog_offset=9 4096,safety_purge=none
+
+# Keyspace
+# Time:2023-03-21 10:00:00
+# Duration: 2s
+db0 Strings_keys=1201133, expires=0, invalid_keys=0
+db0 Hashes_keys=0, expires=0, invalid_keys=0
+db0 Lists_keys=0, expires=0, invalid_keys=0
+db0 Zsets_keys=0, expires=0, invalid_keys=0
+db0 Sets_keys=0, expires=0, invalid_keys=0`
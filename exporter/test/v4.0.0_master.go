@@ -0,0 +1,94 @@
+package test
+
+var V400MasterInfo = `# Server
+pika_version:4.0.0
+pika_git_sha:2f4f6ff1ba4f1bf2d3a30ebbdc1e1c3b3b5c3d10
+pika_build_compile_date: Jun 27 2024
+os:Linux 5.4.119-1-tlinux4-0009.1 x86_64
+arch_bits:64
+process_id:31421
+tcp_port:9221
+thread_num:8
+sync_thread_num:6
+uptime_in_seconds:604821
+uptime_in_days:7
+config_file:/data/pika/conf/pika.conf
+server_id:1
+
+# Data
+db_size:214748364
+db_size_human:204M
+log_size:1073741824
+log_size_human:1024M
+compression:snappy
+used_memory:134217728
+used_memory_human:128M
+db_memtable_usage:100663296
+db_tablereader_usage:33554432
+db_fatal:0
+db_fatal_msg:NULL
+
+# Clients
+connected_clients:12
+
+# Stats
+total_connections_received:9120
+instantaneous_ops_per_sec:1203
+total_commands_processed:731223901
+keyspace_hits:510332011
+keyspace_misses:20339102
+is_bgsaving:No
+is_scaning_keyspace:No
+is_compact:No
+compact_cron:
+compact_interval:
+
+# Command_Exec_Count
+GET:530671113
+SET:200500000
+INFO:52311
+PING:1
+
+# CPU
+used_cpu_sys:3120.02
+used_cpu_user:4801.91
+used_cpu_sys_children:0.00
+used_cpu_user_children:0.00
+
+# Replication(MASTER)
+role:master
+ReplicationID:94e8feeaf9036a77c59ad2f091f96c0b6c6cb5a3e6e9f2ee5b
+connected_slaves:1
+slave0:ip=10.20.30.52,port=9221,conn_fd=91,lag=(db0:0)
+db0 binlog_offset=9 4096,safety_purge=write2file0
+
+# Keyspace
+# Time:2024-07-02 10:00:00
+# Duration: 2s
+db0 Strings_keys=1201133, expires=0, invalid_keys=0
+db0 Hashes_keys=0, expires=0, invalid_keys=0
+db0 Lists_keys=0, expires=0, invalid_keys=0
+db0 Zsets_keys=0, expires=0, invalid_keys=0
+db0 Sets_keys=0, expires=0, invalid_keys=0
+
+
+# Cache
+cache_status:Ok
+cache_db_num:16
+cache_keys:183342
+cache_memory:104857600
+cache_memory_human:100M
+hits:8921034
+all_cmds:10195467
+hits_per_sec:1021
+read_cmd_per_sec:1167
+hitratio_per_sec:87.49%
+hitratio_all:87.5%
+load_keys_per_sec:12
+waitting_load_keys_num:0
+
+# Commandstats
+cmdstat_get:calls=530671113,usec=1061342226,usec_per_call=2.00
+cmdstat_set:calls=200500000,usec=802000000,usec_per_call=4.00
+cmdstat_info:calls=52311,usec=3400215,usec_per_call=65.00
+cmdstat_ping:calls=1,usec=1,usec_per_call=1.00`
@@ -6,6 +6,8 @@ require (
 	github.com/Masterminds/semver v1.5.0
 	github.com/garyburd/redigo v1.6.0
 	github.com/prometheus/client_golang v1.7.1
+	github.com/prometheus/client_model v0.2.0
 	github.com/sirupsen/logrus v1.6.0
 	github.com/stretchr/testify v1.6.1
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
@@ -0,0 +1,144 @@
+// Package respserver is an in-process server speaking the redis protocol for the tests.
+package respserver
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// Status is the status reply, such as +OK.
+type Status string
+
+// Server is an in-process server speaking the redis protocol, the reply of every command is
+// returned by handler.
+type Server struct {
+	l       net.Listener
+	handler func(args []string) interface{}
+	wg      sync.WaitGroup
+}
+
+// New starts a server replying every command by handler. The replies are nil, Status, error, int, string,
+// []string and []interface{} of them.
+func New(t testing.TB, handler func(args []string) interface{}) *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{l: l, handler: handler}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+func (s *Server) Addr() string {
+	return s.l.Addr().String()
+}
+
+func (s *Server) Close() {
+	s.l.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+
+			r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+			for {
+				args, err := readCommand(r)
+				if err != nil {
+					return
+				}
+				writeReply(w, s.handler(args))
+				if w.Flush() != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return nil, fmt.Errorf("unexpected line %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		if line, err = readLine(r); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 {
+		return "", fmt.Errorf("short line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
+
+func writeReply(w *bufio.Writer, reply interface{}) {
+	switch v := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case Status:
+		fmt.Fprintf(w, "+%s\r\n", v)
+	case error:
+		fmt.Fprintf(w, "-%s\r\n", v.Error())
+	case int:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case []string:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, s := range v {
+			writeReply(w, s)
+		}
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, e := range v {
+			writeReply(w, e)
+		}
+	default:
+		panic(fmt.Sprintf("unsupported reply type %T", reply))
+	}
+}
@@ -2,31 +2,52 @@ package main
 
 import (
 	"flag"
+	"io"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/pourer/pika_exporter/config"
 	"github.com/pourer/pika_exporter/discovery"
 	"github.com/pourer/pika_exporter/exporter"
+	"github.com/pourer/pika_exporter/exporter/metrics"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 )
 
 var (
+	configFile         = flag.String("config.file", getEnv("PIKA_EXPORTER_CONFIG_FILE", ""), "Path to YAML or JSON config file of the exporter settings and pika nodes. The flags are the defaults of the settings not in the file.")
 	hostFile           = flag.String("pika.host-file", getEnv("PIKA_HOST_FILE", ""), "Path to file containing one or more pika nodes, separated by newline. NOTE: mutually exclusive with pika.addr.")
+	codisDashboardAddr = flag.String("codis.dashboard-addr", getEnv("PIKA_CODIS_DASHBOARD_ADDR", ""), "Address of codis dashboard, to discover the pika nodes of every codis group. pika.password is used as the codis product auth.")
+	seedAddr           = flag.String("replication.seed-addr", getEnv("PIKA_REPLICATION_SEED_ADDR", ""), "Address of one or more pika masters separated by comma, to discover their slaves and cascaded slaves by INFO REPLICATION.")
+	sentinelAddr       = flag.String("sentinel.addr", getEnv("PIKA_SENTINEL_ADDR", ""), "Address of one or more redis sentinels separated by comma, to discover the pika masters and slaves monitored by sentinel.")
+	refreshInterval    = flag.Duration("discovery.refresh-interval", getEnvDuration("PIKA_EXPORTER_DISCOVERY_REFRESH_INTERVAL", 10*time.Second), "Interval to reload the pika nodes from discovery, such as pika.host-file. If <= 0, not reload.")
 	addr               = flag.String("pika.addr", getEnv("PIKA_ADDR", ""), "Address of one or more pika nodes, separated by comma.")
 	password           = flag.String("pika.password", getEnv("PIKA_PASSWORD", ""), "Password for one or more pika nodes, separated by comma.")
 	alias              = flag.String("pika.alias", getEnv("PIKA_ALIAS", ""), "Pika instance alias for one or more pika nodes, separated by comma.")
+	passwordFile       = flag.String("pika.password-file", getEnv("PIKA_PASSWORD_FILE", ""), "Path to file mapping pika addr to password for the targets of web.scrape-path, in the same format as pika.host-file.")
 	namespace          = flag.String("namespace", getEnv("PIKA_EXPORTER_NAMESPACE", "pika"), "Namespace for metrics.")
-	metricsFile        = flag.String("metrics-file", getEnv("PIKA_EXPORTER_METRICS_FILE", ""), "Metrics definition file.")
+	metricsFile        = flag.String("metrics-file", getEnv("PIKA_EXPORTER_METRICS_FILE", ""), "Path to YAML file of metric definitions, which add to or override the built-in INFO metrics with the same config names.")
 	keySpaceStatsClock = flag.Int("keyspace-stats-clock", getEnvInt("PIKA_EXPORTER_KEYSPACE_STATS_CLOCK", -1), "Stats the number of keys at keyspace-stats-clock o'clock every day, in the range [0, 23].If < 0, not open this feature.")
 	checkKeyPatterns   = flag.String("check.key-patterns", getEnv("PIKA_EXPORTER_CHECK_KEY_PARTTERNS", ""), "Comma separated list of key-patterns to export value and length/size, searched for with SCAN.")
 	checkKeys          = flag.String("check.keys", getEnv("PIKA_EXPORTER_CHECK_KEYS", ""), "Comma separated list of keys to export value and length/size.")
 	checkScanCount     = flag.Int("check.scan-count", getEnvInt("PIKA_EXPORTER_CHECK_SCAN_COUNT", 100), "When check keys and executing SCAN command, scan-count assigned to COUNT.")
+	collectors         = flag.String("collectors", getEnv("PIKA_EXPORTER_COLLECTORS", strings.Join(exporter.DefaultCollectors, ",")), "Comma separated list of the enabled collectors, valid options: "+strings.Join(exporter.Collectors, " ")+".")
+	configParameters   = flag.String("pika.config-parameters", getEnv("PIKA_EXPORTER_CONFIG_PARAMETERS", strings.Join(exporter.DefaultConfigParameters, ",")), "Comma separated list of parameters or patterns of CONFIG GET exported by the config collector.")
+	poolMaxIdle        = flag.Int("pika.pool-max-idle", getEnvInt("PIKA_EXPORTER_POOL_MAX_IDLE", 2), "Maximum number of idle connections kept for each pika node. If < 0, connections are not reused.")
+	poolIdleTimeout    = flag.Duration("pika.pool-idle-timeout", getEnvDuration("PIKA_EXPORTER_POOL_IDLE_TIMEOUT", 5*time.Minute), "Close the connections of a pika node after remaining idle for this duration.")
+	scrapeInterval     = flag.Duration("scrape-interval", getEnvDuration("PIKA_EXPORTER_SCRAPE_INTERVAL", 0), "Interval to scrape the pika nodes in the background, and serve the metrics from the snapshot of the last scrape. If <= 0, the pika nodes are scraped on every request.")
+	scrapeConcurrency  = flag.Int("scrape-concurrency", getEnvInt("PIKA_EXPORTER_SCRAPE_CONCURRENCY", 64), "Maximum number of pika nodes scraped at the same time. If < 0, all of the pika nodes are scraped at the same time.")
+	clientListInterval = flag.Duration("client-list.interval", getEnvDuration("PIKA_EXPORTER_CLIENT_LIST_INTERVAL", time.Minute), "Minimum interval of CLIENT LIST of the clients collector, the scrapes in between export the last result.")
+	clientListTopN     = flag.Int("client-list.top-n", getEnvInt("PIKA_EXPORTER_CLIENT_LIST_TOP_N", 10), "Number of the client IPs with the most connections exported by the clients collector, the others are exported as the IP other.")
+	binlogFileSize     = flag.Int64("pika.binlog-file-size", getEnvInt64("PIKA_EXPORTER_BINLOG_FILE_SIZE", 0), "Binlog file size of the pika nodes in bytes, to convert the binlog offsets to the replication lag in bytes of the replication_lag collector. If <= 0, binlog-file-size of CONFIG GET of each pika node. The master of a slave must be scraped by the addr master_host:master_port of the slave literally, the host names are not resolved.")
 	listenAddress      = flag.String("web.listen-address", getEnv("PIKA_EXPORTER_WEB_LISTEN_ADDRESS", ":9121"), "Address to listen on for web interface and telemetry.")
 	metricPath         = flag.String("web.telemetry-path", getEnv("PIKA_EXPORTER_WEB_TELEMETRY_PATH", "/metrics"), "Path under which to expose metrics.")
+	scrapePath         = flag.String("web.scrape-path", getEnv("PIKA_EXPORTER_WEB_SCRAPE_PATH", ""), "Path under which to expose metrics of the pika node given by the target parameter, such as /scrape. If empty, not exposed.")
+	scrapeAnyTarget    = flag.Bool("web.scrape-any-target", getEnvBool("PIKA_EXPORTER_WEB_SCRAPE_ANY_TARGET", false), "Allow the targets of web.scrape-path which are not in pika.password-file or the config file, they are scraped without password.")
 	logLevel           = flag.String("log.level", getEnv("PIKA_EXPORTER_LOG_LEVEL", "info"), "Log level, valid options: panic fatal error warn warning info debug.")
 	logFormat          = flag.String("log.format", getEnv("PIKA_EXPORTER_LOG_FORMAT", "json"), "Log format, valid options: txt and json.")
 	showVersion        = flag.Bool("version", false, "Show version information and exit.")
@@ -48,6 +69,40 @@ func getEnvInt(key string, defaultVal int) int {
 	return defaultVal
 }
 
+func getEnvInt64(key string, defaultVal int64) int64 {
+	if envVal, ok := os.LookupEnv(key); ok {
+		if v, err := strconv.ParseInt(envVal, 10, 64); err == nil {
+			return v
+		}
+	}
+	return defaultVal
+}
+
+func getEnvBool(key string, defaultVal bool) bool {
+	if envVal, ok := os.LookupEnv(key); ok {
+		if v, err := strconv.ParseBool(envVal); err == nil {
+			return v
+		}
+	}
+	return defaultVal
+}
+
+func getEnvDuration(key string, defaultVal time.Duration) time.Duration {
+	if envVal, ok := os.LookupEnv(key); ok {
+		if v, err := time.ParseDuration(envVal); err == nil {
+			return v
+		}
+	}
+	return defaultVal
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
 func main() {
 	flag.Parse()
 
@@ -56,29 +111,81 @@ func main() {
 		return
 	}
 
-	level, err := log.ParseLevel(*logLevel)
+	cfg := &config.Config{Global: config.Global{
+		Namespace:          *namespace,
+		ListenAddress:      *listenAddress,
+		TelemetryPath:      *metricPath,
+		ScrapePath:         *scrapePath,
+		ScrapeAnyTarget:    *scrapeAnyTarget,
+		LogLevel:           *logLevel,
+		LogFormat:          *logFormat,
+		KeySpaceStatsClock: *keySpaceStatsClock,
+		ScanCount:          *checkScanCount,
+		PoolMaxIdle:        *poolMaxIdle,
+		PoolIdleTimeout:    *poolIdleTimeout,
+		ScrapeInterval:     *scrapeInterval,
+		ScrapeConcurrency:  *scrapeConcurrency,
+		ClientListInterval: *clientListInterval,
+		ClientListTopN:     *clientListTopN,
+		BinlogFileSize:     *binlogFileSize,
+		MetricsFile:        *metricsFile,
+		Collectors:         splitList(*collectors),
+		ConfigParameters:   splitList(*configParameters),
+		CheckKeys:          splitList(*checkKeys),
+		CheckKeyPatterns:   splitList(*checkKeyPatterns),
+	}}
+	if *configFile != "" {
+		var err error
+		if cfg, err = config.LoadFile(*configFile, cfg.Global); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	level, err := log.ParseLevel(cfg.Global.LogLevel)
 	if err != nil {
 		log.Fatalln("parse log.level failed, err:", err)
 	}
 	log.SetLevel(level)
-	switch *logFormat {
+	switch cfg.Global.LogFormat {
 	case "json":
 		log.SetFormatter(&log.JSONFormatter{})
 	default:
 		log.SetFormatter(&log.TextFormatter{})
 	}
 
-	var dis discovery.Discovery
-	if *hostFile != "" {
-		dis, err = discovery.NewFileDiscovery(*hostFile)
-	} else {
+	if cfg.Global.MetricsFile != "" {
+		mcs, err := metrics.LoadFile(cfg.Global.MetricsFile)
+		if err != nil {
+			log.Fatalln("load metrics-file failed. err:", err)
+		}
+		if err := metrics.Override(mcs); err != nil {
+			log.Fatalln("register metrics-file failed. err:", err)
+		}
+	}
+
+	dis := cfg.Discovery()
+	switch {
+	case dis != nil:
+	case *hostFile != "":
+		dis, err = discovery.NewFileDiscovery(*hostFile, *refreshInterval)
+	case *codisDashboardAddr != "":
+		dis, err = discovery.NewCodisDiscovery(*codisDashboardAddr, *password, *refreshInterval)
+	case *seedAddr != "":
+		dis, err = discovery.NewReplicationDiscovery(*seedAddr, *password, *refreshInterval)
+	case *sentinelAddr != "":
+		dis, err = discovery.NewSentinelDiscovery(*sentinelAddr, *password, *refreshInterval)
+	default:
 		dis, err = discovery.NewCmdArgsDiscovery(*addr, *password, *alias)
 	}
 	if err != nil {
 		log.Fatalln(" failed. err:", err)
 	}
+	if closer, ok := dis.(io.Closer); ok {
+		defer closer.Close()
+	}
 
-	e, err := exporter.NewPikaExporter(dis, *namespace, *checkKeyPatterns, *checkKeys, *checkScanCount, *keySpaceStatsClock)
+	opt := cfg.ExporterOptions()
+	e, err := exporter.NewPikaExporter(dis, opt)
 	if err != nil {
 		log.Fatalln("exporter init failed. err:", err)
 	}
@@ -90,23 +197,48 @@ func main() {
 	}, []string{"build_version", "commit_sha", "build_date", "golang_version"})
 	buildInfo.WithLabelValues(BuildVersion, BuildCommitSha, BuildDate, GoVersion).Set(1)
 
-	registry := prometheus.NewRegistry()
-	registry.MustRegister(e)
-	registry.MustRegister(buildInfo)
-	http.Handle(*metricPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	http.Handle(cfg.Global.TelemetryPath, e.Handler(buildInfo))
+
+	if cfg.Global.ScrapePath != "" {
+		var credentials discovery.Discovery
+		if *passwordFile != "" {
+			passwords, err := discovery.NewFileDiscovery(*passwordFile, *refreshInterval)
+			if err != nil {
+				log.Fatalln("load pika.password-file failed. err:", err)
+			}
+			defer passwords.Close()
+			credentials = passwords
+		} else if len(cfg.Instances) > 0 {
+			credentials = dis
+		}
+		if credentials == nil && !cfg.Global.ScrapeAnyTarget {
+			log.Warnln("no pika.password-file or instances of the config file, all the targets of", cfg.Global.ScrapePath, "are refused")
+		}
+		scrapeHandler, err := exporter.NewScrapeHandler(credentials, cfg.Global.ScrapeAnyTarget, opt)
+		if err != nil {
+			log.Fatalln("scrape handler init failed. err:", err)
+		}
+		defer scrapeHandler.Close()
+		http.Handle(cfg.Global.ScrapePath, scrapeHandler)
+	}
+	var scrapeLink string
+	if cfg.Global.ScrapePath != "" {
+		scrapeLink = `<p><a href='` + cfg.Global.ScrapePath + `?target=localhost:9221'>Scrape</a></p>
+`
+	}
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
 <head><title>Pika Exporter v` + BuildVersion + `</title></head>
 <body>
 <h1>Pika Exporter ` + BuildVersion + `</h1>
-<p><a href='` + *metricPath + `'>Metrics</a></p>
-</body>
+<p><a href='` + cfg.Global.TelemetryPath + `'>Metrics</a></p>
+` + scrapeLink + `</body>
 </html>`))
 	})
 
-	log.Printf("Providing metrics on %s%s", *listenAddress, *metricPath)
+	log.Printf("Providing metrics on %s%s", cfg.Global.ListenAddress, cfg.Global.TelemetryPath)
 	for _, instance := range dis.GetInstances() {
 		log.Println("Connecting to Pika:", instance.Addr, "Alias:", instance.Alias)
 	}
-	log.Fatal(http.ListenAndServe(*listenAddress, nil))
+	log.Fatal(http.ListenAndServe(cfg.Global.ListenAddress, nil))
 }